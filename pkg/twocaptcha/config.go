@@ -0,0 +1,139 @@
+package twocaptcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config holds everything needed to create an Instance, e.g. as loaded from a config file or
+// the environment. Captcha types and parameters are given per Task rather than here. In JSON,
+// durations are strings such as "5s" or "1m30s".
+type Config struct {
+	APIKey   string      `json:"apiKey"` // read from APIKeyEnv when empty or masked
+	Settings SettingInfo `json:"settings"`
+}
+
+// NewInstanceFromConfig creates a new Instance from config, validating it like NewInstance. An
+// API key masked by Instance.MarshalJSON counts as empty, so it is read from APIKeyEnv. Proxies
+// whose credentials were masked are dropped likewise and must be set again.
+func NewInstanceFromConfig(config Config) (instance Instance, finalErr error) {
+	if strings.Contains(config.APIKey, "*") {
+		config.APIKey = ""
+	}
+	if maskedProxy(config.Settings.Proxy) {
+		config.Settings.Proxy = ""
+	}
+	var proxies []string
+	for _, proxy := range config.Settings.Proxies {
+		if !maskedProxy(proxy) {
+			proxies = append(proxies, proxy)
+		}
+	}
+	config.Settings.Proxies = proxies
+
+	return NewInstanceContext(context.Background(), config.APIKey, config.Settings)
+}
+
+// duration is a time.Duration encoded in JSON as a string such as "1m30s", so that a bare
+// number of seconds in a config file isn't taken for nanoseconds
+type duration time.Duration
+
+func (value duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(value).String())
+}
+
+func (value *duration) UnmarshalJSON(data []byte) (finalErr error) {
+	if string(data) != "null" {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			finalErr = fmt.Errorf("invalid duration %s (expected a string such as \"5s\")", data)
+		} else {
+			var parsed time.Duration
+			parsed, finalErr = time.ParseDuration(text)
+			*value = duration(parsed)
+		}
+	}
+
+	return finalErr
+}
+
+// settingInfoFields has the fields of SettingInfo without its JSON methods
+type settingInfoFields SettingInfo
+
+// settingInfoJSON is the JSON form of SettingInfo, with its durations encoded as strings
+type settingInfoJSON struct {
+	*settingInfoFields
+	PollInterval    duration `json:"pollInterval"`
+	MaxPollInterval duration `json:"maxPollInterval"`
+	MaxSolveTime    duration `json:"maxSolveTime"`
+	RequestTimeout  duration `json:"requestTimeout"`
+}
+
+// MarshalJSON encodes the settings with durations as strings such as "5s"
+func (settings SettingInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(settingInfoJSON{
+		settingInfoFields: (*settingInfoFields)(&settings),
+		PollInterval:      duration(settings.PollInterval),
+		MaxPollInterval:   duration(settings.MaxPollInterval),
+		MaxSolveTime:      duration(settings.MaxSolveTime),
+		RequestTimeout:    duration(settings.RequestTimeout),
+	})
+}
+
+// UnmarshalJSON decodes settings encoded by MarshalJSON. Durations given as numbers are
+// rejected rather than read as nanoseconds.
+func (settings *SettingInfo) UnmarshalJSON(data []byte) (finalErr error) {
+	decoded := settingInfoJSON{
+		settingInfoFields: (*settingInfoFields)(settings),
+		PollInterval:      duration(settings.PollInterval),
+		MaxPollInterval:   duration(settings.MaxPollInterval),
+		MaxSolveTime:      duration(settings.MaxSolveTime),
+		RequestTimeout:    duration(settings.RequestTimeout),
+	}
+	if finalErr = json.Unmarshal(data, &decoded); finalErr == nil {
+		settings.PollInterval = time.Duration(decoded.PollInterval)
+		settings.MaxPollInterval = time.Duration(decoded.MaxPollInterval)
+		settings.MaxSolveTime = time.Duration(decoded.MaxSolveTime)
+		settings.RequestTimeout = time.Duration(decoded.RequestTimeout)
+	}
+
+	return finalErr
+}
+
+// retryPolicyFields has the fields of RetryPolicy without its JSON methods
+type retryPolicyFields RetryPolicy
+
+// retryPolicyJSON is the JSON form of RetryPolicy, with its durations encoded as strings
+type retryPolicyJSON struct {
+	*retryPolicyFields
+	BaseDelay duration `json:"baseDelay"`
+	MaxDelay  duration `json:"maxDelay"`
+}
+
+// MarshalJSON encodes the policy with durations as strings such as "1s"
+func (policy RetryPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(retryPolicyJSON{
+		retryPolicyFields: (*retryPolicyFields)(&policy),
+		BaseDelay:         duration(policy.BaseDelay),
+		MaxDelay:          duration(policy.MaxDelay),
+	})
+}
+
+// UnmarshalJSON decodes a policy encoded by MarshalJSON. Durations given as numbers are
+// rejected rather than read as nanoseconds.
+func (policy *RetryPolicy) UnmarshalJSON(data []byte) (finalErr error) {
+	decoded := retryPolicyJSON{
+		retryPolicyFields: (*retryPolicyFields)(policy),
+		BaseDelay:         duration(policy.BaseDelay),
+		MaxDelay:          duration(policy.MaxDelay),
+	}
+	if finalErr = json.Unmarshal(data, &decoded); finalErr == nil {
+		policy.BaseDelay = time.Duration(decoded.BaseDelay)
+		policy.MaxDelay = time.Duration(decoded.MaxDelay)
+	}
+
+	return finalErr
+}
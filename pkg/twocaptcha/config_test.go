@@ -0,0 +1,83 @@
+package twocaptcha
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInstanceJSONRoundTrip(t *testing.T) {
+	doer := answering(`{"status":1,"request":"1.5"}`)
+	instance := newTestInstance(t, doer, WithProxies([]string{"user:s3cret@1.2.3.4:8080", "1.2.3.4:8081"}, "HTTP"))
+
+	data, err := json.Marshal(instance)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	for _, secret := range []string{testAPIKey, "s3cret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("encoded instance %s contains %q", data, secret)
+		}
+	}
+	if !strings.Contains(string(data), `"pollInterval":"1s"`) {
+		t.Errorf("encoded instance %s lacks \"pollInterval\":\"1s\"", data)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	config.APIKey = testAPIKey
+	config.Settings.HTTPClient = doer
+	restored, err := NewInstanceFromConfig(config)
+	if err != nil {
+		t.Fatalf("NewInstanceFromConfig: %v", err)
+	}
+	if restored.Settings.PollInterval != time.Second {
+		t.Errorf("restored PollInterval = %v, want 1s", restored.Settings.PollInterval)
+	}
+	if proxies := restored.Settings.Proxies; len(proxies) != 1 || proxies[0] != "1.2.3.4:8081" {
+		t.Errorf("restored Proxies = %q, want the unmasked proxy only", proxies)
+	}
+	if balance, err := restored.GetBalance(); err != nil || balance != 1.5 {
+		t.Errorf("GetBalance = %v, %v, want 1.5, nil", balance, err)
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	var config Config
+	if err := json.Unmarshal([]byte(`{"settings":{"pollInterval":"1m30s","maxSolveTime":null}}`), &config); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if config.Settings.PollInterval != 90*time.Second || config.Settings.MaxSolveTime != 0 {
+		t.Errorf("decoded PollInterval, MaxSolveTime = %v, %v, want 1m30s, 0",
+			config.Settings.PollInterval, config.Settings.MaxSolveTime)
+	}
+	for _, data := range []string{`{"settings":{"pollInterval":5}}`, `{"settings":{"pollInterval":"5 seconds"}}`} {
+		if err := json.Unmarshal([]byte(data), &config); err == nil {
+			t.Errorf("json.Unmarshal(%s) succeeded, want error", data)
+		}
+	}
+}
+
+func TestNewInstanceFromConfig(t *testing.T) {
+	valid := Config{APIKey: testAPIKey, Settings: SettingInfo{PollInterval: time.Second, SkipKeyValidation: true}}
+	if instance, err := NewInstanceFromConfig(valid); err != nil || instance.APIKey != testAPIKey {
+		t.Errorf("NewInstanceFromConfig = %v, %v, want an instance with the API key", instance, err)
+	}
+
+	tests := map[string]func(config *Config){
+		"malformed key":  func(config *Config) { config.APIKey = "key" },
+		"negative poll":  func(config *Config) { config.Settings.PollInterval = -time.Second },
+		"bad proxy type": func(config *Config) { config.Settings.Proxy, config.Settings.ProxyType = "1.2.3.4:8080", "FTP" },
+		"bad base URL":   func(config *Config) { config.Settings.BaseURL = "2captcha.com" },
+	}
+	for name, breakConfig := range tests {
+		config := valid
+		breakConfig(&config)
+		if _, err := NewInstanceFromConfig(config); err == nil {
+			t.Errorf("NewInstanceFromConfig with a %s succeeded, want error", name)
+		}
+	}
+}
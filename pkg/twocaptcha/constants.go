@@ -1,56 +1,181 @@
 package twocaptcha
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var validTypes = []string{
+	"recaptchaV2", "recaptchaV3", "funcaptcha", "hcaptcha", "image", "geetest", "textcaptcha", "capy",
+	"rotate", "audio", "coordinates", "boundingbox", "generic", "turnstile", "geetestv4", "amazonwaf",
+	"cybersiara",
+}
+
+// Captcha types created with a POST, as their content may not fit in a URL
+var postTypes = []string{
+	"image", "textcaptcha", "rotate", "audio", "coordinates", "boundingbox",
+}
+
+var validLanguages = []string{"0", "1", "2"}
+var validAudioLangs = []string{"en", "fr", "de", "el", "pt", "ru"}
+var validProxyTypes = []string{"HTTP", "HTTPS", "SOCKS4", "SOCKS5"}
+
+// Parameters that must not be empty for each captcha type
+var requiredParams = map[string][]string{
+	"image":       {"body"},
+	"rotate":      {"body"},
+	"audio":       {"body"},
+	"coordinates": {"body"},
+	"boundingbox": {"body", "textinstructions"},
+	"generic":     {"method"},
+	"textcaptcha": {"textcaptcha"},
+	"geetest":     {"gt", "challenge", "pageurl"},
+	"capy":        {"captchakey", "pageurl"},
+	"recaptchaV2": {"googlekey", "pageurl"},
+	"recaptchaV3": {"googlekey", "pageurl", "action"},
+	"hcaptcha":    {"sitekey", "pageurl"},
+	"turnstile":   {"sitekey", "pageurl"},
+	"funcaptcha":  {"publickey", "pageurl"},
+	"geetestv4":   {"captcha_id", "pageurl"},
+	"amazonwaf":   {"sitekey", "iv", "context", "pageurl"},
+	"cybersiara":  {"master_url_id", "pageurl", "userAgent"},
+}
+
+// Captcha types each optional TaskOption parameter may be used with
+var taskOptionTypes = map[string][]string{
+	"invisible":        {"recaptchaV2", "hcaptcha"},
+	"enterprise":       {"recaptchaV2", "recaptchaV3"},
+	"data-s":           {"recaptchaV2", "recaptchaV3"},
+	"lang":             {"image", "textcaptcha", "audio"},
+	"language":         {"image", "textcaptcha"},
+	"angle":            {"rotate"},
+	"textinstructions": {"image", "coordinates", "boundingbox"},
+	"userAgent":        {"recaptchaV2", "recaptchaV3", "funcaptcha", "cybersiara", "hcaptcha", "turnstile"},
+	"cookies":          {"recaptchaV2", "recaptchaV3"},
+	"data[blob]":       {"funcaptcha"},
+	"data":             {"turnstile", "hcaptcha"},
+	"pagedata":         {"turnstile"},
+}
+
+// Captcha types solved in a browser by the worker, which can use the caller's proxy
+var proxyTypes = []string{
+	"recaptchaV2", "recaptchaV3", "funcaptcha", "hcaptcha", "geetest", "capy", "generic", "turnstile",
+	"geetestv4", "amazonwaf", "cybersiara",
+}
 
-var validTypes = []string{"recaptchaV2", "recaptchaV3", "funcaptcha"}
-var validV3Scores = []string{".1", ".3", ".9"}
+// APIKeyEnv is the environment variable NewInstance reads the API key from when none is given
+const APIKeyEnv = "TWOCAPTCHA_API_KEY"
 
+// Base URLs of services sharing the 2captcha API, for use as SettingInfo.BaseURL
 const (
-	capRequestURL = "https://2captcha.com/in.php?json=1"
-	capResultURL  = "https://2captcha.com/res.php?json=1"
+	Endpoint2Captcha  = "https://2captcha.com"
+	EndpointRuCaptcha = "https://rucaptcha.com"
 )
 
+const (
+	defaultBaseURL = Endpoint2Captcha
+	capRequestPath = "/in.php?json=1"
+	capResultPath  = "/res.php?json=1"
+
+	maxGetURLLength    = 2048 // longer create requests are sent as POST
+	maxRawBodyLength   = 1024 // longest raw response kept for APIError.Body
+	maxErrorBodyLength = 200  // longest part of an unreadable response quoted in errors
+
+	maskedProxyLogin = "****:****" // replaces proxy credentials in encoded instances
+)
+
+const (
+	defaultHTTPTimeout         = 30 * time.Second
+	defaultTimeBetweenRequests = 5 // seconds
+	defaultMaxSolveTime        = 120 * time.Second
+	defaultMaxAttempts         = 4 // the first request and 3 retries
+	defaultMaxResolves         = 2
+	requestRetryDelay          = time.Second // doubled after every failed attempt
+	userTurnPause              = 10 * time.Second
+	defaultV3Action            = "verify"
+)
+
+// Errors returned by 2captcha. These are returned wrapped in an *APIError, so compare against
+// them with errors.Is.
 var ( // Error return messages (from 2captcha)
-	errorNotReady    = errors.New("handled by program")
-	errorNoSlot      = errors.New("handled by program")
-	errorWrongKey    = errors.New("invalidly formatted api key")
-	errorKeyExist    = errors.New("invalid api key")
-	errorZeroBal     = errors.New("[in] empty account balance")
-	errorIPBan       = errors.New("[in] IP ban, contact 2captcha")
-	errorBadParam    = errors.New("[in] recaptcha invalid token/pageurl")
-	errorSitekey     = errors.New("[in] recaptcha invalid sitekey")
-	errorManyReq     = errors.New("[in] too many requests, temp 10s ban")
-	errorZeroSize    = errors.New("[in] zero captcha filesize")
-	errorUnsolvable  = errors.New("[res] captcha unsolvable")
-	errorIDFormat    = errors.New("[res] invalidly formatted captcha ID")
-	errorWrongID     = errors.New("[res] invalid captcha ID")
-	errorBadDupe     = errors.New("[res] not enough matches")
-	errorEmptyAction = errors.New("[res] action not found")
+	errorNotReady          = errors.New("handled by program")
+	errorNoSlot            = errors.New("handled by program")
+	ErrInvalidAPIKey       = errors.New("invalidly formatted api key")
+	ErrKeyDoesNotExist     = errors.New("invalid api key")
+	ErrZeroBalance         = errors.New("[in] empty account balance")
+	ErrIPBanned            = errors.New("[in] IP banned, use another IP or contact 2captcha")
+	ErrBadTokenOrPageURL   = errors.New("[in] recaptcha invalid token/pageurl")
+	ErrInvalidSitekey      = errors.New("[in] recaptcha invalid sitekey")
+	ErrTooManyRequests     = errors.New("[in] too many requests, temp 10s ban")
+	ErrZeroCaptchaFilesize = errors.New("[in] zero captcha filesize")
+	ErrCaptchaUnsolvable   = errors.New("[res] captcha unsolvable")
+	ErrWrongIDFormat       = errors.New("[res] invalidly formatted captcha ID")
+	ErrWrongCaptchaID      = errors.New("[res] invalid captcha ID")
+	ErrBadDuplicates       = errors.New("[res] not enough matches")
+	ErrEmptyAction         = errors.New("[res] action not found")
+	ErrDuplicateReport     = errors.New("[res] captcha already reported")
+	ErrUnknownAPIError     = errors.New("unknown API error")
 )
 
+// Proxy errors returned by 2captcha, all matching ErrProxy with errors.Is so that callers can
+// switch to another proxy whichever one occurred
+var (
+	ErrProxy                 = errors.New("proxy error")
+	ErrProxyFormat           = fmt.Errorf("%w: [in] invalid proxy format", ErrProxy)
+	ErrBadProxy              = fmt.Errorf("%w: [in] proxy rejected by 2captcha", ErrProxy)
+	ErrProxyConnectionFailed = fmt.Errorf("%w: [res] worker could not connect through proxy", ErrProxy)
+)
+
+// ErrHTMLResponse is returned when 2captcha keeps answering with an HTML page, e.g. a Cloudflare
+// interstitial, instead of JSON. It matches ErrUnmarshal with errors.Is.
+var ErrHTMLResponse = fmt.Errorf("%w: got HTML page instead of JSON (e.g. Cloudflare)", ErrUnmarshal)
+
+// Errors returned by this package before or while talking to 2captcha
 var ( // Error return messages (from program)
-	errorUnmarshal = errors.New("error unmarshalling (shouldn't happen)")
-	errorV3Score   = errors.New("invalid recaptchaV3 minScore (.1/.3/.9)")
+	ErrUnmarshal       = errors.New("error unmarshalling response")
+	ErrHTTPStatus      = errors.New("unexpected HTTP status")
+	ErrEmptyResponse   = errors.New("empty response body")
+	ErrResponseStatus  = errors.New("unexpected 2captcha response status")
+	ErrPingbackPayload = errors.New("pingback payload missing task id")
+	ErrPoolClosed      = errors.New("solver pool closed")
+	ErrClosed          = errors.New("instance closed")
+	ErrV3Score         = errors.New("invalid recaptchaV3 minScore (0.1 to 0.9)")
+	ErrLanguage        = errors.New("invalid language (language 0/1/2, audio lang en/fr/de/el/pt/ru)")
+	ErrMissingParam    = errors.New("missing required captcha parameter")
+	ErrEmptyParam      = errors.New("empty captcha parameter")
+	ErrTaskType        = errors.New("invalid captcha type")
+	ErrProxyType       = errors.New("invalid proxy type (HTTP/HTTPS/SOCKS4/SOCKS5)")
+	ErrBaseURL         = errors.New("invalid base URL (expected scheme://host)")
+	ErrPageURL         = errors.New("invalid page URL (expected absolute http(s) URL)")
+	ErrTaskOption      = errors.New("option not supported by captcha type")
+	ErrSolveTimeout    = errors.New("captcha not solved within MaxSolveTime")
+	ErrSlotRetries     = errors.New("no worker slot available after MaxSlotRetries retries")
+	ErrTokenRejected   = errors.New("solution rejected by ValidateToken after MaxResolves retries")
 )
 
 var captchaErrors = map[string]error{
 	// Automatically handled errors
-	"CAPCHA_NOT_READY":        errorNotReady,
+	"CAPCHA_NOT_READY":        errorNotReady, // sic, this is how 2captcha spells it
 	"ERROR_NO_SLOT_AVAILABLE": errorNoSlot,
 	// API key errors (for both endpoints)
-	"ERROR_WRONG_USER_KEY":     errorWrongKey,
-	"ERROR_KEY_DOES_NOT_EXIST": errorKeyExist,
+	"ERROR_WRONG_USER_KEY":     ErrInvalidAPIKey,
+	"ERROR_KEY_DOES_NOT_EXIST": ErrKeyDoesNotExist,
 	// https://2captcha.com/in.php
-	"ERROR_ZERO_BALANCE":          errorZeroBal,
-	"IP_BANNED":                   errorIPBan,
-	"ERROR_BAD_TOKEN_OR_PAGEURL":  errorBadParam,
-	"ERROR_GOOGLEKEY":             errorSitekey,
-	"MAX_USER_TURN":               errorManyReq,
-	"ERROR_ZERO_CAPTCHA_FILESIZE": errorZeroSize,
+	"ERROR_ZERO_BALANCE":          ErrZeroBalance,
+	"IP_BANNED":                   ErrIPBanned,
+	"ERROR_BAD_TOKEN_OR_PAGEURL":  ErrBadTokenOrPageURL,
+	"ERROR_GOOGLEKEY":             ErrInvalidSitekey,
+	"MAX_USER_TURN":               ErrTooManyRequests,
+	"ERROR_ZERO_CAPTCHA_FILESIZE": ErrZeroCaptchaFilesize,
+	"ERROR_PROXY_FORMAT":          ErrProxyFormat,
+	"ERROR_BAD_PROXY":             ErrBadProxy,
 	// https://2captcha.com/res.php
-	"ERROR_CAPTCHA_UNSOLVABLE": errorUnsolvable,
-	"ERROR_WRONG_ID_FORMAT":    errorIDFormat,
-	"ERROR_WRONG_CAPTCHA_ID":   errorWrongID,
-	"ERROR_BAD_DUPLICATES":     errorBadDupe,
-	"ERROR_EMPTY_ACTION":       errorEmptyAction,
+	"ERROR_CAPTCHA_UNSOLVABLE":      ErrCaptchaUnsolvable,
+	"ERROR_WRONG_ID_FORMAT":         ErrWrongIDFormat,
+	"ERROR_WRONG_CAPTCHA_ID":        ErrWrongCaptchaID,
+	"ERROR_BAD_DUPLICATES":          ErrBadDuplicates,
+	"ERROR_EMPTY_ACTION":            ErrEmptyAction,
+	"ERROR_DUPLICATE_REPORT":        ErrDuplicateReport,
+	"ERROR_PROXY_CONNECTION_FAILED": ErrProxyConnectionFailed,
 }
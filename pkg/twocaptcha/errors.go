@@ -0,0 +1,71 @@
+package twocaptcha
+
+import (
+	"errors"
+	"strings"
+)
+
+// APIError is returned when 2captcha answers a request with one of its error keys. Key is the
+// raw key (e.g. "ERROR_ZERO_BALANCE") and Endpoint is the endpoint that returned it, "in" for
+// in.php or "res" for res.php. Body is the raw response for troubleshooting, cut off after 1024
+// bytes. APIError unwraps to the matching sentinel error.
+type APIError struct {
+	Key      string
+	Message  string
+	Endpoint string
+	Body     string
+	err      error
+}
+
+func (apiErr *APIError) Error() string {
+	return apiErr.Key + ": " + apiErr.Message
+}
+
+// Unwrap returns the sentinel error matching Key
+func (apiErr *APIError) Unwrap() error {
+	return apiErr.err
+}
+
+// ValidationError is returned when a task fails validation before being sent to 2captcha. It
+// lists every problem found, each wrapping one of the package's errors (e.g. ErrMissingParam),
+// and errors.Is matches any of them.
+type ValidationError struct {
+	Problems []error
+}
+
+func (validationErr *ValidationError) Error() string {
+	messages := make([]string, 0, len(validationErr.Problems))
+	for _, problem := range validationErr.Problems {
+		messages = append(messages, problem.Error())
+	}
+
+	return "invalid task: " + strings.Join(messages, "; ")
+}
+
+// Is reports whether any of the problems matches target
+func (validationErr *ValidationError) Is(target error) bool {
+	for _, problem := range validationErr.Problems {
+		if errors.Is(problem, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TimeoutError is returned when solving times out after the task was created with 2captcha.
+// The task may still be solved, so its solution can be fetched later with GetResult using
+// TaskID. TimeoutError unwraps to ErrSolveTimeout or context.DeadlineExceeded.
+type TimeoutError struct {
+	TaskID string
+	err    error
+}
+
+func (timeoutErr *TimeoutError) Error() string {
+	return timeoutErr.err.Error() + " (task " + timeoutErr.TaskID + ")"
+}
+
+// Unwrap returns ErrSolveTimeout or context.DeadlineExceeded
+func (timeoutErr *TimeoutError) Unwrap() error {
+	return timeoutErr.err
+}
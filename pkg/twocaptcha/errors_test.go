@@ -0,0 +1,71 @@
+package twocaptcha
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAPIErrorSentinels(t *testing.T) {
+	tests := map[string]error{
+		"ERROR_ZERO_BALANCE":       ErrZeroBalance,
+		"ERROR_WRONG_USER_KEY":     ErrInvalidAPIKey,
+		"ERROR_KEY_DOES_NOT_EXIST": ErrKeyDoesNotExist,
+		"IP_BANNED":                ErrIPBanned,
+		"ERROR_GOOGLEKEY":          ErrInvalidSitekey,
+	}
+
+	for key, sentinel := range tests {
+		instance := newTestInstance(t, answering(`{"status":0,"request":"`+key+`"}`))
+		if _, err := instance.SubmitTask(NewHCaptchaTask("sitekey", "https://example.com/")); !errors.Is(err, sentinel) {
+			t.Errorf("SubmitTask answered %s: error = %v, want %v", key, err, sentinel)
+		}
+	}
+}
+
+func TestAPIErrorFields(t *testing.T) {
+	body := `{"status":0,"request":"ERROR_CAPTCHA_UNSOLVABLE"}`
+	doer := &fakeDoer{respond: func(request fakeRequest) (int, string, error) {
+		if request.Endpoint == "in" {
+			return 200, `{"status":1,"request":"42"}`, nil
+		}
+		return 200, body, nil
+	}}
+	instance := newTestInstance(t, doer)
+
+	_, err := instance.SolveTask(NewImageTask("aGVsbG8=", ImageOptions{}))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("SolveTask error = %v, want *APIError", err)
+	}
+	if apiErr.Key != "ERROR_CAPTCHA_UNSOLVABLE" || apiErr.Endpoint != "res" || apiErr.Body != body {
+		t.Errorf("APIError = %+v, want key ERROR_CAPTCHA_UNSOLVABLE from res with the raw body", apiErr)
+	}
+	if !errors.Is(err, ErrCaptchaUnsolvable) {
+		t.Errorf("SolveTask error = %v, want ErrCaptchaUnsolvable", err)
+	}
+}
+
+func TestUnknownAPIError(t *testing.T) {
+	instance := newTestInstance(t, answering(`{"status":0,"request":"ERROR_SOMETHING_NEW"}`))
+
+	_, err := instance.SubmitTask(NewHCaptchaTask("sitekey", "https://example.com/"))
+	var apiErr *APIError
+	if !errors.Is(err, ErrUnknownAPIError) || !errors.As(err, &apiErr) || apiErr.Key != "ERROR_SOMETHING_NEW" {
+		t.Errorf("SubmitTask error = %v, want ErrUnknownAPIError keeping the key", err)
+	}
+}
+
+func TestProxyErrors(t *testing.T) {
+	for _, key := range []string{"ERROR_PROXY_FORMAT", "ERROR_BAD_PROXY", "ERROR_PROXY_CONNECTION_FAILED"} {
+		instance := newTestInstance(t, answering(`{"status":0,"request":"`+key+`"}`))
+		_, err := instance.SubmitTask(NewHCaptchaTask("sitekey", "https://example.com/"))
+		if !errors.Is(err, ErrProxy) || !errors.Is(err, captchaErrors[key]) {
+			t.Errorf("SubmitTask answered %s: error = %v, want ErrProxy", key, err)
+		}
+	}
+
+	instance := newTestInstance(t, answering(`{"status":0,"request":"ERROR_ZERO_BALANCE"}`))
+	if _, err := instance.SubmitTask(NewHCaptchaTask("sitekey", "https://example.com/")); errors.Is(err, ErrProxy) {
+		t.Errorf("SubmitTask answered ERROR_ZERO_BALANCE: error = %v, want it not to match ErrProxy", err)
+	}
+}
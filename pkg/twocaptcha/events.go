@@ -0,0 +1,47 @@
+package twocaptcha
+
+// EventType identifies a step in solving a captcha
+type EventType int
+
+// Steps reported to SettingInfo.OnEvent while solving a captcha
+const (
+	EventTaskCreated EventType = iota // task created with 2captcha, TaskID is set
+	EventPoll                         // checking for the solution, Attempt counts from 1
+	EventNotReady                     // the solution isn't ready yet
+	EventSolved                       // the captcha is solved
+	EventError                        // solving failed, Err is set
+)
+
+// Event describes a step in solving a captcha, see SettingInfo.OnEvent
+type Event struct {
+	Type        EventType
+	CaptchaType string
+	TaskID      string
+	Attempt     int
+	Err         error
+	Result      SolveResult // set for EventSolved
+}
+
+func (eventType EventType) String() string {
+	switch eventType {
+	case EventTaskCreated:
+		return "task created"
+	case EventPoll:
+		return "poll"
+	case EventNotReady:
+		return "not ready"
+	case EventSolved:
+		return "solved"
+	case EventError:
+		return "error"
+	}
+
+	return "unknown"
+}
+
+// emit passes event to the OnEvent callback, if one is set
+func (instance *Instance) emit(event Event) {
+	if instance.Settings.OnEvent != nil {
+		instance.Settings.OnEvent(event)
+	}
+}
@@ -0,0 +1,60 @@
+package twocaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestEventOrder(t *testing.T) {
+	var mutex sync.Mutex
+	var events []Event
+	instance := newTestInstance(t, solvingDoer("token", 1), WithOnEvent(func(event Event) {
+		mutex.Lock()
+		events = append(events, event)
+		mutex.Unlock()
+	}))
+
+	if _, err := instance.SolveTask(NewHCaptchaTask("sitekey", "https://example.com/")); err != nil {
+		t.Fatalf("SolveTask: %v", err)
+	}
+	want := []EventType{EventTaskCreated, EventPoll, EventNotReady, EventPoll, EventSolved}
+	if fmt.Sprint(eventTypes(events)) != fmt.Sprint(want) {
+		t.Fatalf("events = %v, want %v", eventTypes(events), want)
+	}
+	if events[0].TaskID != "42" || events[3].Attempt != 2 || events[4].Result.Token != "token" {
+		t.Errorf("events = %+v, want task 42 solved with token on the second poll", events)
+	}
+}
+
+// eventTypes returns the type of each of events
+func eventTypes(events []Event) (types []EventType) {
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+func TestSolveTaskStream(t *testing.T) {
+	instance := newTestInstance(t, solvingDoer("token", 0))
+	var events []Event
+	for event := range instance.SolveTaskStream(context.Background(), NewHCaptchaTask("sitekey", "https://example.com/")) {
+		events = append(events, event)
+	}
+	want := []EventType{EventTaskCreated, EventPoll, EventSolved}
+	if fmt.Sprint(eventTypes(events)) != fmt.Sprint(want) || events[2].Result.Token != "token" {
+		t.Errorf("events = %+v, want %v ending with the token", events, want)
+	}
+
+	instance = newTestInstance(t, answering(`{"status":0,"request":"ERROR_ZERO_BALANCE"}`))
+	events = nil
+	for event := range instance.SolveTaskStream(context.Background(), NewHCaptchaTask("sitekey", "https://example.com/")) {
+		events = append(events, event)
+	}
+	if len(events) != 1 || events[0].Type != EventError || !errors.Is(events[0].Err, ErrZeroBalance) {
+		t.Errorf("events = %+v, want a single EventError with ErrZeroBalance", events)
+	}
+}
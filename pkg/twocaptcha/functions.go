@@ -1,18 +1,80 @@
 package twocaptcha
 
-func containsError(responseStruct *captchaResponse) (finalErr error) {
-	if responseStruct.Status == 0 {
-		for key, value := range captchaErrors {
-			if responseStruct.Response == key {
-				finalErr = value // error
-				break
-			}
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// containsError checks the status of a 2captcha response: 1 is success, 0 (also when the status
+// is missing) an error key turned into an *APIError, and anything else ErrResponseStatus.
+func containsError(responseStruct *captchaResponse, endpoint string) (finalErr error) {
+	switch responseStruct.Status {
+	case 1:
+	case 0:
+		value, ok := captchaErrors[responseStruct.Response]
+		if !ok { // don't mistake errors missing from captchaErrors for a solution
+			value = ErrUnknownAPIError
 		}
+		finalErr = &APIError{
+			Key: responseStruct.Response, Message: value.Error(), Endpoint: endpoint,
+			Body: responseStruct.body, err: value,
+		}
+	default:
+		finalErr = fmt.Errorf("%w %d", ErrResponseStatus, responseStruct.Status)
 	}
 
 	return finalErr
 }
 
+// requestNotSent reports whether err, returned by an HTTP client, shows the request failed
+// before reaching 2captcha: while resolving or dialing its host, or without a free connection
+func requestNotSent(err error) (result bool) {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, fasthttp.ErrDialTimeout), errors.Is(err, fasthttp.ErrNoFreeConns),
+		errors.Is(err, fasthttp.ErrTLSHandshakeTimeout), errors.As(err, &dnsErr):
+		result = true
+	case errors.As(err, &opErr):
+		result = opErr.Op == "dial"
+	}
+
+	return result
+}
+
+// trimParams returns a copy of params with surrounding whitespace removed from every value
+func trimParams(params url.Values) (trimmed url.Values) {
+	trimmed = url.Values{}
+	for key, values := range params {
+		for _, value := range values {
+			trimmed.Add(key, strings.TrimSpace(value))
+		}
+	}
+
+	return trimmed
+}
+
+// truncate shortens value to at most maxLength bytes
+func truncate(value string, maxLength int) (result string) {
+	if result = value; len(result) > maxLength {
+		result = result[:maxLength]
+	}
+
+	return result
+}
+
 func keyInMap(inputMap map[string]string, key string) (result bool) {
 	_, result = inputMap[key]
 	return result
@@ -28,3 +90,223 @@ func stringInSlice(inputSlice []string, key string) (result bool) {
 
 	return result
 }
+
+func sleepContext(ctx context.Context, duration time.Duration) (finalErr error) {
+	timer := time.NewTimer(duration)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		finalErr = ctx.Err()
+	case <-timer.C:
+	}
+
+	return finalErr
+}
+
+// pollDelay returns how long to wait before poll attempt+1. Without a maxInterval above interval
+// every wait is interval; otherwise waits double from interval with up to a quarter of random
+// jitter added, never exceeding maxInterval.
+func pollDelay(interval time.Duration, maxInterval time.Duration, attempt int) (delay time.Duration) {
+	delay = interval
+	if maxInterval > interval {
+		for step := 1; step < attempt && delay < maxInterval; step++ {
+			delay *= 2
+		}
+		if delay/4 > 0 {
+			delay += time.Duration(rand.Int63n(int64(delay / 4)))
+		}
+		if delay > maxInterval {
+			delay = maxInterval
+		}
+	}
+
+	return delay
+}
+
+// parseExtra decodes a solution 2captcha answered with a JSON object into a map of its values.
+// It returns nil for plain string solutions.
+func parseExtra(solution string) (extra map[string]string) {
+	var rawExtra map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(solution), &rawExtra); err == nil {
+		extra = make(map[string]string, len(rawExtra))
+		for key, value := range rawExtra {
+			var text string
+			if err := json.Unmarshal(value, &text); err != nil {
+				text = string(value)
+			}
+			extra[key] = text
+		}
+	}
+
+	return extra
+}
+
+// validBaseURL reports whether baseURL is just an http(s) scheme and host, optionally with a
+// trailing slash
+func validBaseURL(baseURL string) (result bool) {
+	if parsedURL, err := url.Parse(baseURL); err == nil {
+		result = (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != "" &&
+			strings.Trim(parsedURL.Path, "/") == "" && parsedURL.RawQuery == "" && parsedURL.Fragment == ""
+	}
+
+	return result
+}
+
+// parseBalance parses the balance 2captcha answers getBalance with, e.g. "12.3456". Anything
+// but a finite number, such as an HTML error page or "NaN", fails with ErrUnmarshal.
+func parseBalance(response string) (balance float64, finalErr error) {
+	balance, err := strconv.ParseFloat(strings.TrimSpace(response), 64)
+	if err != nil || math.IsNaN(balance) || math.IsInf(balance, 0) {
+		balance, finalErr = 0, fmt.Errorf("%w: balance %q", ErrUnmarshal, response)
+	}
+
+	return balance, finalErr
+}
+
+// maskAPIKey hides all but the last 4 characters of apiKey, for logging
+func maskAPIKey(apiKey string) (masked string) {
+	masked = strings.Repeat("*", len(apiKey))
+	if len(apiKey) > 4 {
+		masked = strings.Repeat("*", len(apiKey)-4) + apiKey[len(apiKey)-4:]
+	}
+
+	return masked
+}
+
+// maskProxy hides the login and password of proxy, if any, for logs and config files
+func maskProxy(proxy string) (masked string) {
+	masked = proxy
+	if at := strings.LastIndex(proxy, "@"); at >= 0 {
+		masked = maskedProxyLogin + proxy[at:]
+	}
+
+	return masked
+}
+
+// maskedProxy reports whether proxy's login and password were hidden by maskProxy
+func maskedProxy(proxy string) (result bool) {
+	return strings.HasPrefix(proxy, maskedProxyLogin+"@")
+}
+
+// validV3Score reports whether score is a plain decimal number from 0.1 to 0.9, the
+// reCAPTCHA v3 scores 2captcha accepts as min_score. Forms ParseFloat also accepts, such as
+// "1e-1", are rejected as score is sent to 2captcha as is.
+func validV3Score(score string) (result bool) {
+	plainDecimal := score != "" && strings.Trim(score, "0123456789.") == "" && strings.Count(score, ".") <= 1
+	if value, err := strconv.ParseFloat(score, 64); err == nil && plainDecimal {
+		result = value >= 0.1 && value <= 0.9
+	}
+
+	return result
+}
+
+// parseStats parses the hourly statistics 2captcha answers getstats with, an XML document of
+// <stats hour=".."><volume>..</volume><money>..</money></stats> elements
+func parseStats(response string) (stats Stats, finalErr error) {
+	var rawStats struct {
+		Hours []HourStats `xml:"stats"`
+	}
+	if err := xml.Unmarshal([]byte(response), &rawStats); err != nil {
+		finalErr = fmt.Errorf("%w: stats %q", ErrUnmarshal, truncate(response, maxErrorBodyLength))
+	}
+	for _, hour := range rawStats.Hours {
+		stats.Volume += hour.Volume
+		stats.Money += hour.Money
+	}
+	stats.Hours = rawStats.Hours
+
+	return stats, finalErr
+}
+
+// validAPIKey reports whether apiKey is formatted like a 2captcha key: 32 hexadecimal characters
+func validAPIKey(apiKey string) (result bool) {
+	result = len(apiKey) == 32
+	for _, char := range apiKey {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", char) {
+			result = false
+		}
+	}
+
+	return result
+}
+
+// validProxy reports whether proxy is formatted as host:port or login:password@host:port
+func validProxy(proxy string) (result bool) {
+	address := proxy
+	if at := strings.LastIndex(proxy, "@"); at >= 0 {
+		address = proxy[at+1:]
+		result = strings.Contains(proxy[:at], ":")
+	} else {
+		result = true
+	}
+	host, port, err := net.SplitHostPort(address)
+	if portNumber, portErr := strconv.Atoi(port); err != nil || portErr != nil || host == "" ||
+		portNumber <= 0 || portNumber > 65535 {
+		result = false
+	}
+
+	return result
+}
+
+// validPageURL reports whether pageURL is an absolute http(s) URL, as 2captcha needs for the
+// page a captcha was found on
+func validPageURL(pageURL string) (result bool) {
+	if parsedURL, err := url.Parse(pageURL); err == nil {
+		result = (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != ""
+	}
+
+	return result
+}
+
+// parseCoordinates parses the points of a solved coordinates captcha. 2captcha answers with a
+// JSON list of {"x": .., "y": ..} objects, or "coordinates:x=39,y=59;x=252,y=72" as plain text.
+func parseCoordinates(solution string) (points []Point, finalErr error) {
+	var rawPoints []map[string]json.Number
+	if err := json.Unmarshal([]byte(solution), &rawPoints); err == nil {
+		for _, rawPoint := range rawPoints {
+			x, errX := strconv.Atoi(rawPoint["x"].String())
+			y, errY := strconv.Atoi(rawPoint["y"].String())
+			if errX != nil || errY != nil {
+				finalErr = ErrUnmarshal
+				break
+			}
+			points = append(points, Point{X: x, Y: y})
+		}
+	} else {
+		for _, rawPoint := range strings.Split(strings.TrimPrefix(solution, "coordinates:"), ";") {
+			var point Point
+			if _, err := fmt.Sscanf(rawPoint, "x=%d,y=%d", &point.X, &point.Y); err != nil {
+				finalErr = ErrUnmarshal
+				break
+			}
+			points = append(points, point)
+		}
+	}
+
+	return points, finalErr
+}
+
+// parseBoxes parses the boxes of a solved bounding box captcha, which 2captcha answers with as
+// a JSON list of {"x1": .., "y1": .., "x2": .., "y2": ..} objects
+func parseBoxes(solution string) (boxes []Rectangle, finalErr error) {
+	var rawBoxes []map[string]json.Number
+	if err := json.Unmarshal([]byte(solution), &rawBoxes); err != nil {
+		finalErr = ErrUnmarshal
+	}
+	for _, rawBox := range rawBoxes {
+		var corners [4]int
+		for index, key := range []string{"x1", "y1", "x2", "y2"} {
+			if corner, err := strconv.Atoi(rawBox[key].String()); err != nil {
+				finalErr = ErrUnmarshal
+			} else {
+				corners[index] = corner
+			}
+		}
+		if finalErr != nil {
+			break
+		}
+		boxes = append(boxes, Rectangle{X1: corners[0], Y1: corners[1], X2: corners[2], Y2: corners[3]})
+	}
+
+	return boxes, finalErr
+}
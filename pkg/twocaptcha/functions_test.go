@@ -0,0 +1,76 @@
+package twocaptcha
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseCoordinates(t *testing.T) {
+	tests := map[string][]Point{
+		`[{"x":"39","y":"59"},{"x":252,"y":72}]`: {{X: 39, Y: 59}, {X: 252, Y: 72}},
+		"coordinates:x=39,y=59;x=252,y=72":       {{X: 39, Y: 59}, {X: 252, Y: 72}},
+	}
+	for solution, want := range tests {
+		if points, err := parseCoordinates(solution); err != nil || fmt.Sprint(points) != fmt.Sprint(want) {
+			t.Errorf("parseCoordinates(%q) = %v, %v, want %v, nil", solution, points, err, want)
+		}
+	}
+
+	for _, solution := range []string{"coordinates:x=39;y=59", `[{"x":"a","y":1}]`, "ERROR"} {
+		if _, err := parseCoordinates(solution); !errors.Is(err, ErrUnmarshal) {
+			t.Errorf("parseCoordinates(%q) error = %v, want ErrUnmarshal", solution, err)
+		}
+	}
+}
+
+func TestParseBoxes(t *testing.T) {
+	solution := `[{"x1":1,"y1":"2","x2":30,"y2":40}]`
+	if boxes, err := parseBoxes(solution); err != nil || len(boxes) != 1 || boxes[0] != (Rectangle{1, 2, 30, 40}) {
+		t.Errorf("parseBoxes(%q) = %v, %v, want one box 1,2 30,40", solution, boxes, err)
+	}
+
+	for _, solution := range []string{`[{"x1":1,"y1":2,"x2":30}]`, "ERROR"} {
+		if _, err := parseBoxes(solution); !errors.Is(err, ErrUnmarshal) {
+			t.Errorf("parseBoxes(%q) error = %v, want ErrUnmarshal", solution, err)
+		}
+	}
+}
+
+func TestPollDelay(t *testing.T) {
+	if delay := pollDelay(time.Second, 0, 5); delay != time.Second {
+		t.Errorf("pollDelay without maxInterval = %v, want 1s", delay)
+	}
+
+	var previous time.Duration
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay := pollDelay(time.Second, 10*time.Second, attempt)
+		if delay < previous*4/5 || delay > 10*time.Second {
+			t.Errorf("pollDelay attempt %d = %v after %v, want growing up to 10s", attempt, delay, previous)
+		}
+		previous = delay
+	}
+	if previous < 10*time.Second*4/5 {
+		t.Errorf("pollDelay attempt 10 = %v, want about 10s", previous)
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	response := `<?xml version="1.0"?><response>` +
+		`<stats dateint="1600000000" date="2020-09-13" hour="0"><volume>3</volume><money>0.00867</money></stats>` +
+		`<stats dateint="1600003600" date="2020-09-13" hour="1"><volume>2</volume><money>0.00578</money></stats>` +
+		`</response>`
+
+	stats, err := parseStats(response)
+	if err != nil || stats.Volume != 5 || len(stats.Hours) != 2 || stats.Hours[1] != (HourStats{Hour: 1, Volume: 2, Money: 0.00578}) {
+		t.Errorf("parseStats = %+v, %v, want 5 captchas over 2 hours", stats, err)
+	}
+	if stats.Money < 0.01444 || stats.Money > 0.01446 {
+		t.Errorf("parseStats money = %v, want 0.01445", stats.Money)
+	}
+
+	if _, err := parseStats("ERROR"); !errors.Is(err, ErrUnmarshal) {
+		t.Errorf("parseStats error = %v, want ErrUnmarshal", err)
+	}
+}
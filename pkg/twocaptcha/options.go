@@ -0,0 +1,188 @@
+package twocaptcha
+
+import (
+	"context"
+	"time"
+)
+
+// Option configures the SettingInfo used by NewInstanceWithOptions
+type Option func(settings *SettingInfo)
+
+// WithTimeBetweenRequests sets the number of seconds to wait between polling requests
+func WithTimeBetweenRequests(seconds int) Option {
+	return func(settings *SettingInfo) {
+		settings.TimeBetweenRequests = seconds
+	}
+}
+
+// WithPollInterval sets the time to wait between polling requests, taking precedence over
+// WithTimeBetweenRequests
+func WithPollInterval(interval time.Duration) Option {
+	return func(settings *SettingInfo) {
+		settings.PollInterval = interval
+	}
+}
+
+// WithMaxPollInterval makes polling back off exponentially, with jitter, from the poll interval
+// up to maxInterval, saving requests on captchas that take long to solve
+func WithMaxPollInterval(maxInterval time.Duration) Option {
+	return func(settings *SettingInfo) {
+		settings.MaxPollInterval = maxInterval
+	}
+}
+
+// WithMaxSolveTime sets the maximum time spent solving a single captcha, including waiting
+// for a free worker slot
+func WithMaxSolveTime(maxSolveTime time.Duration) Option {
+	return func(settings *SettingInfo) {
+		settings.MaxSolveTime = maxSolveTime
+	}
+}
+
+// WithMaxSlotRetries sets how many times task creation is retried while no worker slot is
+// available or 2captcha answers MAX_USER_TURN, 0 meaning unlimited
+func WithMaxSlotRetries(retries int) Option {
+	return func(settings *SettingInfo) {
+		settings.MaxSlotRetries = retries
+	}
+}
+
+// WithRefreshBalance makes every successful solve fetch the remaining balance into
+// SolveResult.Balance, at the cost of an extra request per solve
+func WithRefreshBalance() Option {
+	return func(settings *SettingInfo) {
+		settings.RefreshBalance = true
+	}
+}
+
+// WithSkipKeyValidation creates the instance without the balance request NewInstance otherwise
+// makes to check the API key, e.g. when the key is known to be good. The key's format is still
+// checked.
+func WithSkipKeyValidation() Option {
+	return func(settings *SettingInfo) {
+		settings.SkipKeyValidation = true
+	}
+}
+
+// WithRequestTimeout sets the maximum time a single HTTP request to 2captcha may take before it
+// is abandoned and retried
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(settings *SettingInfo) {
+		settings.RequestTimeout = timeout
+	}
+}
+
+// WithRetryPolicy sets how failed HTTP requests to 2captcha are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(settings *SettingInfo) {
+		settings.RetryPolicy = policy
+	}
+}
+
+// WithSleep replaces every wait between requests to 2captcha with sleep, e.g. to skip waiting in
+// tests
+func WithSleep(sleep func(ctx context.Context, duration time.Duration) error) Option {
+	return func(settings *SettingInfo) {
+		settings.Sleep = sleep
+	}
+}
+
+// WithPingback sets the URL 2captcha sends solutions to
+func WithPingback(pingbackURL string) Option {
+	return func(settings *SettingInfo) {
+		settings.Pingback = pingbackURL
+	}
+}
+
+// WithSoftID sets the 2captcha developer ID credited for created tasks
+func WithSoftID(softID string) Option {
+	return func(settings *SettingInfo) {
+		settings.SoftID = softID
+	}
+}
+
+// WithOnEvent sets the callback notified at each step of solving a captcha
+func WithOnEvent(onEvent func(event Event)) Option {
+	return func(settings *SettingInfo) {
+		settings.OnEvent = onEvent
+	}
+}
+
+// WithOnSolveComplete sets the callback called with the result of every solve
+func WithOnSolveComplete(onSolveComplete func(result SolveResult, err error)) Option {
+	return func(settings *SettingInfo) {
+		settings.OnSolveComplete = onSolveComplete
+	}
+}
+
+// WithBaseURL sets the base URL (scheme and host) of the 2captcha compatible API to use, such as
+// EndpointRuCaptcha
+func WithBaseURL(baseURL string) Option {
+	return func(settings *SettingInfo) {
+		settings.BaseURL = baseURL
+	}
+}
+
+// WithHTTPClient sets the client used for all requests to 2captcha, usually a *fasthttp.Client.
+// The instance only sends requests through the client, so one client can be shared by any
+// number of instances.
+func WithHTTPClient(client Doer) Option {
+	return func(settings *SettingInfo) {
+		settings.HTTPClient = client
+	}
+}
+
+// WithProxy sets the proxy forwarded to 2captcha, where proxyType is one of HTTP, HTTPS, SOCKS4
+// or SOCKS5
+func WithProxy(address string, proxyType string) Option {
+	return func(settings *SettingInfo) {
+		settings.Proxy = address
+		settings.ProxyType = proxyType
+	}
+}
+
+// WithProxies sets proxies forwarded to 2captcha in turn, one per created task, where proxyType
+// is one of HTTP, HTTPS, SOCKS4 or SOCKS5. Malformed proxies are skipped.
+func WithProxies(proxies []string, proxyType string) Option {
+	return func(settings *SettingInfo) {
+		settings.Proxies = proxies
+		settings.ProxyType = proxyType
+	}
+}
+
+// WithProxylessFallback makes a captcha solved through the proxy that 2captcha reports
+// unsolvable get one more try without the proxy
+func WithProxylessFallback() Option {
+	return func(settings *SettingInfo) {
+		settings.ProxylessFallback = true
+	}
+}
+
+// WithHeaderACAO makes 2captcha answer task creation and polling with CORS headers
+// (header_acao=1), for solutions polled from a browser
+func WithHeaderACAO() Option {
+	return func(settings *SettingInfo) {
+		settings.HeaderACAO = true
+	}
+}
+
+// WithValidateToken sets a check every solution must pass before it is returned. Rejected
+// solutions are reported bad and solved again up to maxResolves times.
+func WithValidateToken(validateToken func(token string) bool, maxResolves int) Option {
+	return func(settings *SettingInfo) {
+		settings.ValidateToken = validateToken
+		settings.MaxResolves = maxResolves
+	}
+}
+
+// NewInstanceWithOptions creates a new Instance like NewInstance, but builds its settings from
+// opts applied over the defaults (polling every defaultTimeBetweenRequests seconds). Settings
+// are validated the same way as in NewInstance.
+func NewInstanceWithOptions(apiKey string, opts ...Option) (instance Instance, finalErr error) {
+	settings := SettingInfo{TimeBetweenRequests: defaultTimeBetweenRequests}
+	for _, opt := range opts {
+		opt(&settings)
+	}
+
+	return NewInstance(apiKey, settings)
+}
@@ -0,0 +1,113 @@
+package twocaptcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// SolverPool solves tasks on a fixed number of workers sharing one Instance. At most
+// maxInFlight tasks are queued or being solved at a time; further calls to Solve wait for room.
+// When 2captcha answers MAX_USER_TURN every worker pauses before creating more tasks, as all
+// solves on the instance do (see SettingInfo.MaxSlotRetries), and the affected task is retried
+// until MaxSlotRetries is used up.
+type SolverPool struct {
+	instance  *Instance
+	jobs      chan poolJob
+	slots     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type poolJob struct {
+	ctx     context.Context
+	task    Task
+	results chan poolResult
+}
+
+type poolResult struct {
+	result SolveResult
+	err    error
+}
+
+// NewSolverPool creates a SolverPool solving with instance and starts its workers. Call Close
+// to stop them once the pool is no longer needed.
+func NewSolverPool(instance *Instance, workers int, maxInFlight int) (pool *SolverPool, finalErr error) {
+OuterLoop:
+	for {
+		if workers <= 0 || maxInFlight <= 0 {
+			finalErr = errors.New("invalid SolverPool workers/maxInFlight value")
+			break OuterLoop
+		}
+
+		pool = &SolverPool{
+			instance: instance,
+			jobs:     make(chan poolJob),
+			slots:    make(chan struct{}, maxInFlight),
+			done:     make(chan struct{}),
+		}
+		for worker := 0; worker < workers; worker++ {
+			go pool.work()
+		}
+		break OuterLoop
+	}
+
+	return pool, finalErr
+}
+
+func (pool *SolverPool) work() {
+OuterLoop:
+	for {
+		select {
+		case <-pool.done:
+			break OuterLoop
+		case job := <-pool.jobs:
+			var result poolResult
+			result.result, result.err = pool.instance.SolveTaskContext(job.ctx, job.task)
+			job.results <- result
+		}
+	}
+}
+
+// Solve queues task on the pool and waits for its result, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (pool *SolverPool) Solve(ctx context.Context, task Task) (result SolveResult, finalErr error) {
+OuterLoop:
+	for {
+		select {
+		case <-pool.done:
+			finalErr = ErrPoolClosed
+			break OuterLoop
+		case <-ctx.Done():
+			finalErr = ctx.Err()
+			break OuterLoop
+		case pool.slots <- struct{}{}:
+		}
+		defer func() { <-pool.slots }()
+
+		job := poolJob{ctx: ctx, task: task, results: make(chan poolResult, 1)}
+		select {
+		case <-pool.done:
+			finalErr = ErrPoolClosed
+			break OuterLoop
+		case <-ctx.Done():
+			finalErr = ctx.Err()
+			break OuterLoop
+		case pool.jobs <- job:
+		}
+
+		jobResult := <-job.results
+		result, finalErr = jobResult.result, jobResult.err
+		break OuterLoop
+	}
+
+	return result, finalErr
+}
+
+// Close stops the pool's workers once they finish their current task. Solve returns
+// ErrPoolClosed afterwards.
+func (pool *SolverPool) Close() {
+	pool.closeOnce.Do(func() {
+		close(pool.done)
+	})
+}
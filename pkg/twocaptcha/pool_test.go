@@ -0,0 +1,51 @@
+package twocaptcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolSolves(t *testing.T) {
+	doer := solvingDoer("token", 1)
+	instance := newTestInstance(t, doer)
+	pool, err := NewSolverPool(&instance, 2, 4)
+	if err != nil {
+		t.Fatalf("NewSolverPool: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for solve := 0; solve < 3; solve++ {
+		if result, err := pool.Solve(ctx, NewHCaptchaTask("sitekey", "https://example.com/")); err != nil || result.Token != "token" {
+			t.Errorf("Solve = %+v, %v, want token", result, err)
+		}
+	}
+
+	pool.Close()
+	if _, err := pool.Solve(ctx, NewHCaptchaTask("sitekey", "https://example.com/")); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Solve after Close: error = %v, want ErrPoolClosed", err)
+	}
+}
+
+func TestPoolStopsAfterMaxSlotRetries(t *testing.T) {
+	doer := answering(`{"status":0,"request":"MAX_USER_TURN"}`)
+	instance := newTestInstance(t, doer, WithMaxSlotRetries(2))
+	pool, err := NewSolverPool(&instance, 4, 8)
+	if err != nil {
+		t.Fatalf("NewSolverPool: %v", err)
+	}
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for solve := 0; solve < 4; solve++ {
+		if _, err := pool.Solve(ctx, NewHCaptchaTask("sitekey", "https://example.com/")); !errors.Is(err, ErrTooManyRequests) {
+			t.Errorf("Solve error = %v, want ErrTooManyRequests", err)
+		}
+	}
+	if requests := len(doer.received("in", "")); requests != 4*3 {
+		t.Errorf("sent %d create requests, want %d", requests, 4*3)
+	}
+}
@@ -0,0 +1,64 @@
+package twocaptcha
+
+import (
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how failed HTTP requests to 2captcha are retried. A request is sent at
+// most MaxAttempts times; the wait before retry n (counting from 0) is BaseDelay multiplied by
+// Multiplier n times, capped at MaxDelay when set, with up to Jitter (a fraction, e.g. 0.2) of
+// it added at random. A zero Multiplier means 2. When MaxAttempts is 0 the default policy is
+// used: 4 attempts, with a BaseDelay of 1s if none is set.
+type RetryPolicy struct {
+	MaxAttempts int           `json:"maxAttempts"`
+	BaseDelay   time.Duration `json:"baseDelay"`
+	MaxDelay    time.Duration `json:"maxDelay"`
+	Multiplier  float64       `json:"multiplier"`
+	Jitter      float64       `json:"jitter"`
+}
+
+// retryPolicy returns Settings.RetryPolicy with the defaults filled in when MaxAttempts is 0, as
+// NewInstance does, for instances not created by it
+func (instance *Instance) retryPolicy() (policy RetryPolicy) {
+	return instance.Settings.RetryPolicy.withDefaults()
+}
+
+// withDefaults returns the policy, or the default policy when MaxAttempts is 0
+func (policy RetryPolicy) withDefaults() RetryPolicy {
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = defaultMaxAttempts
+		if policy.BaseDelay == 0 {
+			policy.BaseDelay = requestRetryDelay
+		}
+	}
+
+	return policy
+}
+
+// delay returns how long to wait before retry number retry, counting from 0
+func (policy RetryPolicy) delay(retry int) (delay time.Duration) {
+	multiplier := policy.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	delay = policy.BaseDelay
+	for step := 0; step < retry && (policy.MaxDelay == 0 || delay < policy.MaxDelay); step++ {
+		delay = time.Duration(float64(delay) * multiplier)
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	if jitter := int64(float64(delay) * policy.Jitter); jitter > 0 {
+		delay += time.Duration(rand.Int63n(jitter))
+	}
+
+	return delay
+}
+
+// valid reports whether the policy's values make sense, treating the zero policy as valid
+func (policy RetryPolicy) valid() (result bool) {
+	return policy.MaxAttempts >= 0 && policy.BaseDelay >= 0 && policy.MaxDelay >= 0 &&
+		(policy.Multiplier == 0 || policy.Multiplier >= 1) && policy.Jitter >= 0 && policy.Jitter <= 1
+}
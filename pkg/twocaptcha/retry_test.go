@@ -0,0 +1,33 @@
+package twocaptcha
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for retry, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if delay := policy.delay(retry); delay != want {
+			t.Errorf("delay(%d) = %v, want %v", retry, delay, want)
+		}
+	}
+
+	policy = RetryPolicy{BaseDelay: time.Second, Multiplier: 3, Jitter: 0.5}
+	for retry := 0; retry < 3; retry++ {
+		base := time.Second
+		for step := 0; step < retry; step++ {
+			base *= 3
+		}
+		if delay := policy.delay(retry); delay < base || delay >= base*3/2 {
+			t.Errorf("delay(%d) = %v, want from %v up to half more", retry, delay, base)
+		}
+	}
+
+	if defaults := (RetryPolicy{}).withDefaults(); defaults.MaxAttempts != defaultMaxAttempts || defaults.BaseDelay != requestRetryDelay {
+		t.Errorf("withDefaults = %+v, want %d attempts %v apart", defaults, defaultMaxAttempts, requestRetryDelay)
+	}
+	if (RetryPolicy{Multiplier: 0.5}).valid() || (RetryPolicy{Jitter: 2}).valid() || !(RetryPolicy{}).valid() {
+		t.Error("valid accepts a shrinking multiplier or jitter above 1, or rejects the zero policy")
+	}
+}
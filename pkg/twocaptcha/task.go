@@ -0,0 +1,475 @@
+package twocaptcha
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Task describes a single captcha to be solved: its type (one of the supported captcha types,
+// e.g. "recaptchaV2") and the parameters sent to 2captcha when creating it. The API key is added
+// by the Instance solving the task and should not be included in Params.
+type Task struct {
+	Type   string
+	Params url.Values
+}
+
+// SolveResult contains a solved captcha along with details about how it was solved. Token holds
+// the solution 2captcha returned; for captcha types answered with several values (GeeTest,
+// Capy) those values are also available in Extra. Polls counts the requests made checking for
+// the solution. Balance is the account balance after solving, only fetched when
+// SettingInfo.RefreshBalance is set and left at 0 if fetching it failed.
+type SolveResult struct {
+	Token    string
+	TaskID   string
+	Type     string
+	Extra    map[string]string
+	Duration time.Duration
+	Polls    int
+	Balance  float64
+
+	Coordinates []Point     // points to click, for coordinates captchas
+	Boxes       []Rectangle // boxes drawn, for bounding box captchas
+}
+
+// Point is a position on a captcha image in pixels, from the top left corner
+type Point struct {
+	X int
+	Y int
+}
+
+// Rectangle is a box on a captcha image, given by its top left (X1, Y1) and bottom right
+// (X2, Y2) corners
+type Rectangle struct {
+	X1 int
+	Y1 int
+	X2 int
+	Y2 int
+}
+
+// TaskOption sets an optional parameter on a Task
+type TaskOption func(task *Task)
+
+// Invisible marks a RecaptchaV2 or hCaptcha task as an invisible widget
+func Invisible() TaskOption {
+	return func(task *Task) {
+		task.Params.Set("invisible", "1")
+	}
+}
+
+// Enterprise marks a RecaptchaV2 or RecaptchaV3 task as reCAPTCHA Enterprise
+func Enterprise() TaskOption {
+	return func(task *Task) {
+		task.Params.Set("enterprise", "1")
+	}
+}
+
+// DataS sets the data-s value found on some reCAPTCHA widgets (mostly Google properties).
+// Nothing is sent if value is empty.
+func DataS(value string) TaskOption {
+	return func(task *Task) {
+		if value != "" {
+			task.Params.Set("data-s", value)
+		}
+	}
+}
+
+// Lang sets the language code of the captcha text (e.g. "en", "ru"), used to pick workers who
+// speak it
+func Lang(code string) TaskOption {
+	return func(task *Task) {
+		task.Params.Set("lang", code)
+	}
+}
+
+// Language sets the alphabet of the captcha text: 0 not specified, 1 Cyrillic, 2 Latin
+func Language(language int) TaskOption {
+	return func(task *Task) {
+		task.Params.Set("language", strconv.Itoa(language))
+	}
+}
+
+// Angle sets the rotation step in degrees of a rotate captcha, 40 if not set
+func Angle(degrees int) TaskOption {
+	return func(task *Task) {
+		task.Params.Set("angle", strconv.Itoa(degrees))
+	}
+}
+
+// TextInstructions sets instructions shown to the worker along with the image
+func TextInstructions(text string) TaskOption {
+	return func(task *Task) {
+		task.Params.Set("textinstructions", text)
+	}
+}
+
+// UserAgent sets the User-Agent of the browser the token will be used in, which 2captcha's
+// workers then solve with, for reCAPTCHA, FunCaptcha, hCaptcha, Turnstile and CyberSiARA tasks.
+// Nothing is sent if userAgent is empty.
+func UserAgent(userAgent string) TaskOption {
+	return func(task *Task) {
+		if userAgent != "" {
+			task.Params.Set("userAgent", userAgent)
+		}
+	}
+}
+
+// Cookies sets cookies for 2captcha's workers to use while solving a reCAPTCHA, sent as
+// name1:value1;name2:value2 sorted by name. Nothing is sent if cookies is empty. Combine it
+// with UserAgent to tie the token to an existing browser session; both are sent together.
+func Cookies(cookies map[string]string) TaskOption {
+	return func(task *Task) {
+		names := make([]string, 0, len(cookies))
+		for name := range cookies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		pairs := make([]string, 0, len(names))
+		for _, name := range names {
+			pairs = append(pairs, name+":"+cookies[name])
+		}
+		if len(pairs) > 0 {
+			task.Params.Set("cookies", strings.Join(pairs, ";"))
+		}
+	}
+}
+
+// RqData sets the rqdata value some (mostly enterprise) hCaptcha widgets are rendered with.
+// Nothing is sent if value is empty.
+func RqData(value string) TaskOption {
+	return func(task *Task) {
+		if value != "" {
+			task.Params.Set("data", value)
+		}
+	}
+}
+
+// Blob sets the data[blob] value many Arkose FunCaptcha challenges are loaded with. Nothing is
+// sent if value is empty.
+func Blob(value string) TaskOption {
+	return func(task *Task) {
+		if value != "" {
+			task.Params.Set("data[blob]", value)
+		}
+	}
+}
+
+// CData sets the cData value passed to a Cloudflare Turnstile widget, needed for some managed
+// challenges. Nothing is sent if value is empty.
+func CData(value string) TaskOption {
+	return func(task *Task) {
+		if value != "" {
+			task.Params.Set("data", value)
+		}
+	}
+}
+
+// PageData sets the chlPageData value passed to a Cloudflare Turnstile widget, needed for some
+// managed challenges. Nothing is sent if value is empty.
+func PageData(value string) TaskOption {
+	return func(task *Task) {
+		if value != "" {
+			task.Params.Set("pagedata", value)
+		}
+	}
+}
+
+func (task *Task) apply(opts []TaskOption) {
+	for _, opt := range opts {
+		opt(task)
+	}
+}
+
+// NewRecaptchaV2Task creates a Google RecaptchaV2 Task
+func NewRecaptchaV2Task(sitekey string, siteurl string, opts ...TaskOption) (task Task) {
+	task = Task{Type: "recaptchaV2", Params: url.Values{}}
+	task.Params.Set("method", "userrecaptcha")
+	task.Params.Set("googlekey", sitekey)
+	task.Params.Set("pageurl", siteurl)
+	task.apply(opts)
+
+	return task
+}
+
+// NewRecaptchaV3Task creates a Google RecaptchaV3 Task. An empty action defaults to "verify".
+// minScore must be between 0.1 and 0.9 (e.g. ".3" or "0.7"), which is checked when the task is
+// solved.
+func NewRecaptchaV3Task(
+	sitekey string, siteurl string, action string, minScore string, opts ...TaskOption,
+) (task Task) {
+	task = Task{Type: "recaptchaV3", Params: url.Values{}}
+	task.Params.Set("method", "userrecaptcha")
+	task.Params.Set("version", "v3")
+	task.Params.Set("googlekey", sitekey)
+	task.Params.Set("pageurl", siteurl)
+	if action = strings.TrimSpace(action); action == "" {
+		action = defaultV3Action
+	}
+	task.Params.Set("action", action)
+	task.Params.Set("min_score", minScore)
+	task.apply(opts)
+
+	return task
+}
+
+// NewFuncaptchaTask creates an Arkose Funcaptcha Task. surl is optional and not sent when empty.
+// UserAgent() and Blob() are accepted as options.
+func NewFuncaptchaTask(sitekey string, surl string, siteurl string, opts ...TaskOption) (task Task) {
+	task = Task{Type: "funcaptcha", Params: url.Values{}}
+	task.Params.Set("method", "funcaptcha")
+	task.Params.Set("publickey", sitekey)
+	if surl != "" {
+		task.Params.Set("surl", surl)
+	}
+	task.Params.Set("pageurl", siteurl)
+	task.apply(opts)
+
+	return task
+}
+
+// NewHCaptchaTask creates an hCaptcha Task. Invisible() and RqData() are accepted as options.
+func NewHCaptchaTask(sitekey string, siteurl string, opts ...TaskOption) (task Task) {
+	task = Task{Type: "hcaptcha", Params: url.Values{}}
+	task.Params.Set("method", "hcaptcha")
+	task.Params.Set("sitekey", sitekey)
+	task.Params.Set("pageurl", siteurl)
+	task.apply(opts)
+
+	return task
+}
+
+// NewTurnstileTask creates a Cloudflare Turnstile Task. CData() and PageData() are accepted as
+// options.
+func NewTurnstileTask(sitekey string, siteurl string, opts ...TaskOption) (task Task) {
+	task = Task{Type: "turnstile", Params: url.Values{}}
+	task.Params.Set("method", "turnstile")
+	task.Params.Set("sitekey", sitekey)
+	task.Params.Set("pageurl", siteurl)
+	task.apply(opts)
+
+	return task
+}
+
+// NewImageTask creates a normal image captcha Task from a base64 encoded image. Lang() and
+// Language() are accepted as options.
+func NewImageTask(body string, options ImageOptions, opts ...TaskOption) (task Task) {
+	task = Task{Type: "image", Params: url.Values{}}
+	task.Params.Set("method", "base64")
+	task.Params.Set("body", body)
+	if options.Phrase {
+		task.Params.Set("phrase", "1")
+	}
+	if options.CaseSensitive {
+		task.Params.Set("regsense", "1")
+	}
+	if options.Calc {
+		task.Params.Set("calc", "1")
+	}
+	if options.Numeric > 0 {
+		task.Params.Set("numeric", strconv.Itoa(options.Numeric))
+	}
+	if options.MinLength > 0 {
+		task.Params.Set("min_len", strconv.Itoa(options.MinLength))
+	}
+	if options.MaxLength > 0 {
+		task.Params.Set("max_len", strconv.Itoa(options.MaxLength))
+	}
+	task.apply(opts)
+
+	return task
+}
+
+// NewTextTask creates a text captcha Task, where text is the question to answer (e.g. "What
+// color is the sky?"). Lang() and Language() are accepted as options.
+func NewTextTask(text string, opts ...TaskOption) (task Task) {
+	task = Task{Type: "textcaptcha", Params: url.Values{}}
+	task.Params.Set("method", "post")
+	task.Params.Set("textcaptcha", text)
+	task.apply(opts)
+
+	return task
+}
+
+// NewGeeTestTask creates a GeeTest (v3) Task. apiServer is optional and not sent when empty.
+func NewGeeTestTask(gt string, challenge string, apiServer string, siteurl string) (task Task) {
+	task = Task{Type: "geetest", Params: url.Values{}}
+	task.Params.Set("method", "geetest")
+	task.Params.Set("gt", gt)
+	task.Params.Set("challenge", challenge)
+	if apiServer != "" {
+		task.Params.Set("api_server", apiServer)
+	}
+	task.Params.Set("pageurl", siteurl)
+
+	return task
+}
+
+// NewGeeTestV4Task creates a GeeTest v4 Task, where captchaID is the captcha_id the page
+// initializes GeeTest with
+func NewGeeTestV4Task(captchaID string, siteurl string) (task Task) {
+	task = Task{Type: "geetestv4", Params: url.Values{}}
+	task.Params.Set("method", "geetest_v4")
+	task.Params.Set("captcha_id", captchaID)
+	task.Params.Set("pageurl", siteurl)
+
+	return task
+}
+
+// NewAmazonWAFTask creates an Amazon WAF captcha Task, where sitekey, iv and wafContext are the
+// key, iv and context values found on the captcha page
+func NewAmazonWAFTask(sitekey string, iv string, wafContext string, siteurl string) (task Task) {
+	task = Task{Type: "amazonwaf", Params: url.Values{}}
+	task.Params.Set("method", "amazon_waf")
+	task.Params.Set("sitekey", sitekey)
+	task.Params.Set("iv", iv)
+	task.Params.Set("context", wafContext)
+	task.Params.Set("pageurl", siteurl)
+
+	return task
+}
+
+// NewCyberSiARATask creates a CyberSiARA Task, where masterURLID is the MasterUrlId found on
+// the page and userAgent the User-Agent of the browser the token will be used in
+func NewCyberSiARATask(masterURLID string, siteurl string, userAgent string) (task Task) {
+	task = Task{Type: "cybersiara", Params: url.Values{}}
+	task.Params.Set("method", "cybersiara")
+	task.Params.Set("master_url_id", masterURLID)
+	task.Params.Set("pageurl", siteurl)
+	task.Params.Set("userAgent", userAgent)
+
+	return task
+}
+
+// NewRotateTask creates a rotate captcha Task from a base64 encoded image. Angle() is accepted
+// as an option.
+func NewRotateTask(body string, opts ...TaskOption) (task Task) {
+	task = Task{Type: "rotate", Params: url.Values{}}
+	task.Params.Set("method", "rotatecaptcha")
+	task.Params.Set("body", body)
+	task.apply(opts)
+
+	return task
+}
+
+// NewAudioTask creates an audio captcha Task from a base64 encoded MP3 file, where lang is the
+// language spoken: "en", "fr", "de", "el", "pt" or "ru"
+func NewAudioTask(body string, lang string) (task Task) {
+	task = Task{Type: "audio", Params: url.Values{}}
+	task.Params.Set("method", "audio")
+	task.Params.Set("body", body)
+	task.Params.Set("lang", lang)
+
+	return task
+}
+
+// NewCoordinatesTask creates a coordinates ("click on all the ...") captcha Task from a base64
+// encoded image. TextInstructions() is accepted as an option.
+func NewCoordinatesTask(body string, opts ...TaskOption) (task Task) {
+	task = Task{Type: "coordinates", Params: url.Values{}}
+	task.Params.Set("method", "base64")
+	task.Params.Set("coordinatescaptcha", "1")
+	task.Params.Set("body", body)
+	task.apply(opts)
+
+	return task
+}
+
+// NewBoundingBoxTask creates a bounding box captcha Task from a base64 encoded image, where
+// instructions tell the worker what to draw boxes around
+func NewBoundingBoxTask(body string, instructions string) (task Task) {
+	task = Task{Type: "boundingbox", Params: url.Values{}}
+	task.Params.Set("method", "bounding_box_captcha")
+	task.Params.Set("body", body)
+	task.Params.Set("textinstructions", instructions)
+
+	return task
+}
+
+// NewCapyTask creates a Capy Puzzle Task
+func NewCapyTask(captchakey string, siteurl string) (task Task) {
+	task = Task{Type: "capy", Params: url.Values{}}
+	task.Params.Set("method", "capy")
+	task.Params.Set("captchakey", captchakey)
+	task.Params.Set("pageurl", siteurl)
+
+	return task
+}
+
+// NewGenericTask creates a Task for any 2captcha method, including ones this package has no
+// dedicated constructor for. params are sent as given along with method, so they must use the
+// parameter names 2captcha documents for that method.
+func NewGenericTask(method string, params map[string]string) (task Task) {
+	task = Task{Type: "generic", Params: url.Values{}}
+	for param, value := range params {
+		task.Params.Set(param, value)
+	}
+	task.Params.Set("method", method)
+
+	return task
+}
+
+// validateTask checks task before it is sent to 2captcha. Every problem found is reported at
+// once in a *ValidationError.
+func validateTask(task Task) (finalErr error) {
+	var problems []error
+	if !stringInSlice(validTypes, task.Type) {
+		problems = append(problems, fmt.Errorf("%w: %q", ErrTaskType, task.Type))
+	}
+	if task.Type == "recaptchaV3" && !validV3Score(task.Params.Get("min_score")) {
+		problems = append(problems, ErrV3Score)
+	}
+	if task.Params.Get("language") != "" && !stringInSlice(validLanguages, task.Params.Get("language")) {
+		problems = append(problems, ErrLanguage)
+	}
+	if task.Type == "audio" && !stringInSlice(validAudioLangs, task.Params.Get("lang")) {
+		problems = append(problems, ErrLanguage)
+	}
+	if task.Params["pageurl"] != nil && !validPageURL(task.Params.Get("pageurl")) {
+		problems = append(problems, ErrPageURL)
+	}
+	for _, param := range requiredParams[task.Type] {
+		if strings.TrimSpace(task.Params.Get(param)) == "" {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrMissingParam, param))
+		}
+	}
+	params := make([]string, 0, len(task.Params))
+	for param := range task.Params {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		if strings.TrimSpace(task.Params.Get(param)) == "" && !stringInSlice(requiredParams[task.Type], param) {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrEmptyParam, param))
+		}
+	}
+
+	options := make([]string, 0, len(taskOptionTypes))
+	for param := range taskOptionTypes {
+		options = append(options, param)
+	}
+	sort.Strings(options)
+	for _, param := range options {
+		// generic tasks carry whatever parameters their method takes
+		_, ok := task.Params[param]
+		if ok && task.Type != "generic" && !stringInSlice(taskOptionTypes[param], task.Type) {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrTaskOption, param))
+		}
+	}
+
+	if len(problems) > 0 {
+		finalErr = &ValidationError{Problems: problems}
+	}
+
+	return finalErr
+}
+
+// usesPost reports whether the task has to be created with a POST, which is the case for
+// captchas sent as file bodies or free text, and any task with a body parameter.
+func (task Task) usesPost() (result bool) {
+	_, hasBody := task.Params["body"]
+	return hasBody || stringInSlice(postTypes, task.Type)
+}
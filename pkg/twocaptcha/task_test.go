@@ -0,0 +1,281 @@
+package twocaptcha
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// createParams returns the parameters creating task would send, failing the test if the
+// request can't be built
+func createParams(t *testing.T, instance Instance, task Task) (method string, params url.Values) {
+	t.Helper()
+
+	requestURL, postBody, err := instance.BuildCreateRequest(task)
+	if err != nil {
+		t.Fatalf("BuildCreateRequest: %v", err)
+	}
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		t.Fatalf("BuildCreateRequest URL %q: %v", requestURL, err)
+	}
+	params, method = parsedURL.Query(), "GET"
+	if postBody != "" {
+		form, err := url.ParseQuery(postBody)
+		if err != nil {
+			t.Fatalf("BuildCreateRequest body %q: %v", postBody, err)
+		}
+		for key, values := range form {
+			params[key] = values
+		}
+		method = "POST"
+	}
+
+	return method, params
+}
+
+func TestParamsTrimmed(t *testing.T) {
+	instance := newTestInstance(t, answering(""))
+
+	_, params := createParams(t, instance, NewRecaptchaV2Task(" sitekey\n", "\thttps://example.com/ "))
+	if params.Get("googlekey") != "sitekey" || params.Get("pageurl") != "https://example.com/" {
+		t.Errorf("params = %v, want trimmed googlekey and pageurl", params)
+	}
+
+	_, params = createParams(t, instance, NewFuncaptchaTask("publickey", "", "https://example.com/"))
+	if _, ok := params["surl"]; ok {
+		t.Errorf("params = %v, want no surl", params)
+	}
+
+	tests := []Task{
+		NewRecaptchaV2Task(" ", "https://example.com/"),
+		NewRecaptchaV3Task("", "https://example.com/", "verify", "0.3"),
+		NewHCaptchaTask("\n", "https://example.com/"),
+		NewTurnstileTask("", "https://example.com/"),
+		NewFuncaptchaTask("", "", "https://example.com/"),
+	}
+	for _, task := range tests {
+		if _, _, err := instance.BuildCreateRequest(task); !errors.Is(err, ErrMissingParam) ||
+			errors.Is(err, ErrEmptyParam) {
+			t.Errorf("%s task without key: error = %v, want ErrMissingParam only", task.Type, err)
+		}
+	}
+}
+
+func TestBuildCreateRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		task    Task
+		method  string
+		want    map[string]string
+	}{
+		{
+			name:   "hcaptcha",
+			task:   NewHCaptchaTask("sitekey", "https://example.com/", Invisible(), RqData("rqdata")),
+			method: "GET",
+			want: map[string]string{
+				"method": "hcaptcha", "sitekey": "sitekey", "pageurl": "https://example.com/",
+				"invisible": "1", "data": "rqdata",
+			},
+		},
+		{
+			name:   "image",
+			task:   NewImageTask("aGVsbG8=", ImageOptions{Phrase: true, CaseSensitive: true, Numeric: 1}, Lang("en"), Language(2)),
+			method: "POST",
+			want: map[string]string{
+				"method": "base64", "body": "aGVsbG8=", "phrase": "1", "regsense": "1", "numeric": "1",
+				"lang": "en", "language": "2",
+			},
+		},
+		{
+			name:   "geetest",
+			task:   NewGeeTestTask("gt", "challenge", "api.geetest.com", "https://example.com/"),
+			method: "GET",
+			want: map[string]string{
+				"method": "geetest", "gt": "gt", "challenge": "challenge", "api_server": "api.geetest.com",
+				"pageurl": "https://example.com/",
+			},
+		},
+		{
+			name:    "proxy",
+			options: []Option{WithProxy("user:p@ss@1.2.3.4:8080", "SOCKS5")},
+			task:    NewRecaptchaV2Task("sitekey", "https://example.com/"),
+			method:  "GET",
+			want:    map[string]string{"proxy": "user:p@ss@1.2.3.4:8080", "proxytype": "SOCKS5"},
+		},
+		{
+			name:   "pageurl with query",
+			task:   NewRecaptchaV2Task("sitekey", "https://example.com/?foo=bar&baz=qux"),
+			method: "GET",
+			want:   map[string]string{"pageurl": "https://example.com/?foo=bar&baz=qux", "googlekey": "sitekey"},
+		},
+		{
+			name:   "invisible and enterprise recaptcha",
+			task:   NewRecaptchaV2Task("sitekey", "https://example.com/", Invisible(), Enterprise()),
+			method: "GET",
+			want:   map[string]string{"method": "userrecaptcha", "invisible": "1", "enterprise": "1"},
+		},
+		{
+			name: "recaptcha session",
+			task: NewRecaptchaV3Task("sitekey", "https://example.com/", "login", "0.7", DataS("data-s"),
+				UserAgent("Mozilla/5.0"), Cookies(map[string]string{"b": "2", "a": "1"})),
+			method: "GET",
+			want: map[string]string{
+				"version": "v3", "action": "login", "min_score": "0.7", "data-s": "data-s",
+				"userAgent": "Mozilla/5.0", "cookies": "a:1;b:2",
+			},
+		},
+		{
+			name:    "instance settings",
+			options: []Option{WithSoftID("1234"), WithHeaderACAO(), WithPingback("https://example.com/pingback")},
+			task:    NewTextTask("What color is the sky?", Lang("en")),
+			method:  "POST",
+			want: map[string]string{
+				"method": "post", "textcaptcha": "What color is the sky?", "lang": "en", "soft_id": "1234",
+				"header_acao": "1", "pingback": "https://example.com/pingback",
+			},
+		},
+		{
+			name:   "turnstile",
+			task:   NewTurnstileTask("sitekey", "https://example.com/", CData("cdata"), PageData("pagedata")),
+			method: "GET",
+			want:   map[string]string{"method": "turnstile", "data": "cdata", "pagedata": "pagedata"},
+		},
+		{
+			name:   "funcaptcha",
+			task:   NewFuncaptchaTask("publickey", "https://client-api.arkoselabs.com", "https://example.com/", Blob("blob")),
+			method: "GET",
+			want: map[string]string{
+				"method": "funcaptcha", "publickey": "publickey", "surl": "https://client-api.arkoselabs.com",
+				"data[blob]": "blob",
+			},
+		},
+		{
+			name:   "geetest v4",
+			task:   NewGeeTestV4Task("captchaid", "https://example.com/"),
+			method: "GET",
+			want:   map[string]string{"method": "geetest_v4", "captcha_id": "captchaid"},
+		},
+		{
+			name:   "amazon waf",
+			task:   NewAmazonWAFTask("sitekey", "iv", "context", "https://example.com/"),
+			method: "GET",
+			want:   map[string]string{"method": "amazon_waf", "sitekey": "sitekey", "iv": "iv", "context": "context"},
+		},
+		{
+			name:   "cybersiara",
+			task:   NewCyberSiARATask("masterurlid", "https://example.com/", "Mozilla/5.0"),
+			method: "GET",
+			want:   map[string]string{"method": "cybersiara", "master_url_id": "masterurlid", "userAgent": "Mozilla/5.0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			instance := newTestInstance(t, answering(""), test.options...)
+
+			method, params := createParams(t, instance, test.task)
+			if method != test.method {
+				t.Errorf("method = %s, want %s", method, test.method)
+			}
+			if params.Get("key") != testAPIKey {
+				t.Errorf("key = %q, want the API key", params.Get("key"))
+			}
+			for param, want := range test.want {
+				if got := params.Get(param); got != want {
+					t.Errorf("%s = %q, want %q", param, got, want)
+				}
+			}
+		})
+	}
+
+	instance := newTestInstance(t, answering(""), WithProxy("1.2.3.4:8080", "HTTP"))
+	if _, params := createParams(t, instance, NewImageTask("aGVsbG8=", ImageOptions{})); params.Get("proxy") != "" {
+		t.Errorf("image task params = %v, want no proxy", params)
+	}
+	for _, task := range []Task{
+		NewTurnstileTask("sitekey", "https://example.com/", Invisible()),
+		NewHCaptchaTask("sitekey", "https://example.com/", Blob("blob")),
+		NewImageTask("aGVsbG8=", ImageOptions{}, Cookies(map[string]string{"a": "1"})),
+	} {
+		if _, _, err := instance.BuildCreateRequest(task); !errors.Is(err, ErrTaskOption) {
+			t.Errorf("%s task with a foreign option: error = %v, want ErrTaskOption", task.Type, err)
+		}
+	}
+}
+
+func TestV3ScoreValidated(t *testing.T) {
+	instance := newTestInstance(t, answering(""))
+
+	for score, valid := range map[string]bool{
+		"0.1": true, ".3": true, "0.9": true, "0.05": false, "1": false, "0.91": false, "1e-1": false,
+		"": false, "abc": false,
+	} {
+		_, _, err := instance.BuildCreateRequest(NewRecaptchaV3Task("sitekey", "https://example.com/", "verify", score))
+		if valid != (err == nil) || (!valid && !errors.Is(err, ErrV3Score)) {
+			t.Errorf("min_score %q: error = %v, want valid %v", score, err, valid)
+		}
+	}
+}
+
+func TestV3ActionDefaulted(t *testing.T) {
+	instance := newTestInstance(t, answering(""))
+
+	for action, want := range map[string]string{"": "verify", "  ": "verify", " login ": "login"} {
+		_, params := createParams(t, instance, NewRecaptchaV3Task("sitekey", "https://example.com/", action, "0.3"))
+		if params.Get("action") != want {
+			t.Errorf("action %q sent as %q, want %q", action, params.Get("action"), want)
+		}
+	}
+}
+
+func TestLongRequestPosted(t *testing.T) {
+	instance := newTestInstance(t, answering(""))
+
+	if method, _ := createParams(t, instance, NewRecaptchaV2Task("sitekey", "https://example.com/", DataS("short"))); method != "GET" {
+		t.Errorf("method with a short data-s = %s, want GET", method)
+	}
+	dataS := strings.Repeat("a", maxGetURLLength)
+	method, params := createParams(t, instance, NewRecaptchaV2Task("sitekey", "https://example.com/", DataS(dataS)))
+	if method != "POST" || params.Get("data-s") != dataS {
+		t.Errorf("method with a long data-s = %s, want POST carrying it", method)
+	}
+}
+
+func TestPageURLValidated(t *testing.T) {
+	instance := newTestInstance(t, answering(""))
+
+	tests := map[string]error{
+		"https://example.com/login": nil,
+		"http://example.com":        nil,
+		"example.com/login":         ErrPageURL,
+		"":                          ErrMissingParam,
+	}
+	for pageURL, want := range tests {
+		_, _, err := instance.BuildCreateRequest(NewHCaptchaTask("sitekey", pageURL))
+		if (want == nil) != (err == nil) || !errors.Is(err, want) {
+			t.Errorf("pageurl %q: error = %v, want %v", pageURL, err, want)
+		}
+	}
+}
+
+func TestValidationProblemsAggregated(t *testing.T) {
+	instance := newTestInstance(t, answering(""))
+
+	task := NewRecaptchaV3Task("", "example.com", "verify", "2", Invisible())
+	_, _, err := instance.BuildCreateRequest(task)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Problems) != 4 {
+		t.Fatalf("BuildCreateRequest error = %v, want a ValidationError with 4 problems", err)
+	}
+	for _, problem := range []error{ErrMissingParam, ErrPageURL, ErrV3Score, ErrTaskOption} {
+		if !errors.Is(err, problem) {
+			t.Errorf("BuildCreateRequest error = %v, want it to match %v", err, problem)
+		}
+	}
+	if errors.Is(err, ErrLanguage) {
+		t.Errorf("BuildCreateRequest error = %v, want it not to match ErrLanguage", err)
+	}
+}
@@ -1,9 +1,16 @@
 package twocaptcha
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/valyala/fasthttp"
@@ -12,112 +19,688 @@ import (
 // SettingInfo contains settings info like time between successive checking requests. These
 // settings are passed into the captcha constructor by the user.
 type SettingInfo struct {
-	TimeBetweenRequests int
+	// seconds between polling requests
+	TimeBetweenRequests int `json:"timeBetweenRequests"`
+	// takes precedence over TimeBetweenRequests when set
+	PollInterval time.Duration `json:"pollInterval"`
+	// when above PollInterval, polling backs off up to it
+	MaxPollInterval time.Duration `json:"maxPollInterval"`
+	// bound on solving a single captcha, defaultMaxSolveTime if 0
+	MaxSolveTime time.Duration `json:"maxSolveTime"`
+	// retries while no worker is free or on MAX_USER_TURN, 0 is unlimited
+	MaxSlotRetries int `json:"maxSlotRetries"`
+	// retries of failed HTTP requests, see RetryPolicy for defaults
+	RetryPolicy RetryPolicy `json:"retryPolicy"`
+	// bound on a single HTTP request, defaultHTTPTimeout if 0
+	RequestTimeout time.Duration `json:"requestTimeout"`
+	// e.g. EndpointRuCaptcha, defaultBaseURL if empty
+	BaseURL string `json:"baseURL"`
+	// fetch SolveResult.Balance after each solve, one extra request
+	RefreshBalance bool `json:"refreshBalance"`
+	// NewInstance doesn't check the key by fetching the balance
+	SkipKeyValidation bool `json:"skipKeyValidation"`
+
+	// Optional parameters sent along when creating tasks. Proxy (login:password@host:port or
+	// host:port) and ProxyType are forwarded so the worker solves token captchas from the same
+	// IP as the caller. Proxies, when set, are rotated across created tasks instead of Proxy,
+	// malformed entries skipped. Pingback is a URL 2captcha sends solutions to (see SubmitTask),
+	// SoftID the 2captcha developer ID credited for created tasks.
+	Proxy     string   `json:"proxy"`
+	Proxies   []string `json:"proxies"`
+	ProxyType string   `json:"proxyType"`
+	Pingback  string   `json:"pingback"`
+	SoftID    string   `json:"softID"`
+
+	// retry once without Proxy when a proxied captcha is unsolvable
+	ProxylessFallback bool `json:"proxylessFallback"`
+	// have 2captcha send CORS headers when creating and polling tasks
+	HeaderACAO bool `json:"headerACAO"`
+
+	// ValidateToken, when set, checks every solution before it is returned, e.g. against the
+	// target site. Rejected solutions are reported bad and solved again up to MaxResolves times
+	// (defaultMaxResolves if 0), after which ErrTokenRejected is returned.
+	ValidateToken func(token string) bool `json:"-"`
+	MaxResolves   int                     `json:"maxResolves"`
+
+	// Optional callbacks. OnEvent is called at each step of solving a captcha (see EventType),
+	// OnSolveComplete with the result of every solve (e.g. to record metrics). Both must be safe
+	// for concurrent use when solving concurrently.
+	OnEvent         func(event Event)                   `json:"-"`
+	OnSolveComplete func(result SolveResult, err error) `json:"-"`
+
+	HTTPClient Doer `json:"-"` // when nil a client with read/write timeouts shared by instances is used
+
+	// Sleep replaces every wait between requests (polling, retries, pauses) when set, e.g. to
+	// run tests without waiting. It must return ctx.Err() if ctx is done before duration passes.
+	Sleep func(ctx context.Context, duration time.Duration) error `json:"-"`
 }
 
 // Instance contains fields required for interfacing with the 2captcha API including the user's
 // API key, necessary settings (time between requests) and HTTP client for sending requests.
+// A single instance solves every supported captcha type, so one per API key is enough.
+// Solving does not modify the instance, so its methods are safe for concurrent use as long as
+// its fields aren't changed meanwhile.
 type Instance struct {
 	APIKey     string
 	Settings   SettingInfo
-	HTTPClient *fasthttp.Client
+	HTTPClient Doer
+
+	closed     *int32  // set by Close, shared by copies of the instance
+	proxyIndex *uint32 // next of Settings.Proxies to use, shared by copies of the instance
+	pausedTill *int64  // end of the MAX_USER_TURN pause in Unix nanoseconds, shared likewise
 }
 
+// defaultHTTPClient is used by instances created without an HTTPClient, so that they share one
+// connection pool to 2captcha
+var defaultHTTPClient = &fasthttp.Client{
+	ReadTimeout:  defaultHTTPTimeout,
+	WriteTimeout: defaultHTTPTimeout,
+}
+
+// Doer sends HTTP requests. It is satisfied by *fasthttp.Client, and can be replaced to wrap or
+// fake the transport used to talk to 2captcha.
+type Doer interface {
+	Do(request *fasthttp.Request, response *fasthttp.Response) error
+	DoDeadline(request *fasthttp.Request, response *fasthttp.Response, deadline time.Time) error
+}
+
+// captchaResponse is the JSON body of every in.php and res.php answer. What the request field
+// holds depends on the call: an error key (e.g. ERROR_ZERO_BALANCE) when Status is 0, otherwise
+// the task ID for task creation, the solution for action=get, the balance for
+// action=getBalance, or a confirmation such as OK_REPORT_RECORDED for reports.
 type captchaResponse struct {
-	Status   int    `json:"status"` // 0 means error, 1 represents valid request
-	Response string `json:"request"`
+	Status   int    `json:"status"`  // 0 means error, 1 represents valid request
+	Response string `json:"request"` // task ID, solution, balance or error key, see above
+
+	body string // raw response, truncated to maxRawBodyLength, for error reporting
+}
+
+// UnmarshalJSON decodes a 2captcha response. Most solutions are plain strings, but some
+// captcha types (GeeTest) answer with a JSON object, in which case Response holds the raw
+// object for the caller to decode.
+func (responseStruct *captchaResponse) UnmarshalJSON(data []byte) (finalErr error) {
+	var rawResponse struct {
+		Status   int             `json:"status"`
+		Response json.RawMessage `json:"request"`
+	}
+
+	if finalErr = json.Unmarshal(data, &rawResponse); finalErr == nil {
+		responseStruct.body = truncate(string(data), maxRawBodyLength)
+		responseStruct.Status = rawResponse.Status
+		if err := json.Unmarshal(rawResponse.Response, &responseStruct.Response); err != nil {
+			responseStruct.Response = string(rawResponse.Response)
+		}
+	}
+
+	return finalErr
 }
 
-func checkResponse(response *fasthttp.Response) (result bool) {
-	result = true
+// checkResponse verifies response is a usable answer from 2captcha. Server errors, empty bodies
+// and HTML pages (such as Cloudflare interstitials served with status 200) are worth retrying,
+// any other unexpected status is not.
+func checkResponse(response *fasthttp.Response) (retry bool, finalErr error) {
+	switch statusCode := response.StatusCode(); {
+	case statusCode >= fasthttp.StatusInternalServerError:
+		finalErr = fmt.Errorf("%w %d", ErrHTTPStatus, statusCode)
+		retry = true
+	case statusCode != fasthttp.StatusOK:
+		finalErr = fmt.Errorf("%w %d", ErrHTTPStatus, statusCode)
+	case len(response.Body()) == 0:
+		finalErr = ErrEmptyResponse
+		retry = true
+	case bytes.HasPrefix(bytes.TrimSpace(response.Body()), []byte("<")):
+		finalErr = fmt.Errorf("%w: %q", ErrHTMLResponse, truncate(string(response.Body()), maxErrorBodyLength))
+		retry = true
+	}
+
+	return retry, finalErr
+}
+
+func (instance *Instance) capRequestURL() string {
+	return instance.baseURL() + capRequestPath
+}
+
+func (instance *Instance) capResultURL() string {
+	return instance.baseURL() + capResultPath
+}
+
+func (instance *Instance) baseURL() (baseURL string) {
+	if baseURL = strings.TrimSuffix(instance.Settings.BaseURL, "/"); baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	return baseURL
+}
+
+// sendRequest sends a request to requestURL and unmarshals the 2captcha response into
+// responseStruct. The request is a GET unless postForm is non-nil, in which case postForm is
+// sent as a url-encoded POST body. Each HTTP call is bounded by Settings.RequestTimeout and by
+// ctx's deadline, if any, and ctx is checked before every attempt. Failed HTTP calls, server
+// errors and empty responses are retried with exponential backoff as set by the instance's
+// RetryPolicy. Repeating an in.php call could create and bill a second task, so those are only
+// retried on server errors, HTML pages and network errors from before the request reached
+// 2captcha (see requestNotSent), not on timeouts, empty bodies or unreadable responses.
+func (instance *Instance) sendRequest(
+	ctx context.Context, requestURL string, postForm url.Values, responseStruct *captchaResponse,
+) (finalErr error) {
+	ctxDeadline, hasDeadline := ctx.Deadline()
+	policy := instance.retryPolicy()
+	unmarshalRetried := false
+	createsTask := strings.HasPrefix(requestURL, instance.capRequestURL())
+
+	for attempt := 0; ; attempt++ {
+		if finalErr = ctx.Err(); finalErr != nil {
+			break
+		}
+		if instance.closed != nil && atomic.LoadInt32(instance.closed) != 0 {
+			finalErr = ErrClosed
+			break
+		}
+
+		request := fasthttp.AcquireRequest()
+		request.SetRequestURI(requestURL)
+		if postForm != nil {
+			request.Header.SetMethod("POST")
+			request.Header.SetContentType("application/x-www-form-urlencoded")
+			request.SetBodyString(postForm.Encode())
+		} else {
+			request.Header.SetMethod("GET")
+		}
+
+		deadline := ctxDeadline
+		if timeout := instance.Settings.RequestTimeout; timeout > 0 {
+			if requestDeadline := time.Now().Add(timeout); !hasDeadline || requestDeadline.Before(deadline) {
+				deadline = requestDeadline
+			}
+		}
+
+		response := fasthttp.AcquireResponse()
+		var err error
+		if !deadline.IsZero() {
+			err = instance.HTTPClient.DoDeadline(request, response, deadline)
+		} else {
+			err = instance.HTTPClient.Do(request, response)
+		}
+
+		retryRequest := false
+		switch {
+		case err == fasthttp.ErrTimeout && hasDeadline && !time.Now().Before(ctxDeadline):
+			finalErr = context.DeadlineExceeded
+		case err != nil: // network errors are usually transient, try again after a while
+			finalErr = fmt.Errorf("request to 2captcha failed: %w", err)
+			retryRequest = !createsTask || requestNotSent(err)
+		default:
+			// an empty answer to in.php may come after the task was created, so isn't retried
+			retryRequest, finalErr = checkResponse(response)
+			retryRequest = retryRequest && !(createsTask && errors.Is(finalErr, ErrEmptyResponse))
+			if finalErr == nil {
+				// retried once in case the body was cut short, unless 2captcha may have created a
+				// task already
+				if err := json.Unmarshal(response.Body(), responseStruct); err != nil {
+					finalErr = fmt.Errorf("%w: %q", ErrUnmarshal, truncate(string(response.Body()), maxErrorBodyLength))
+					retryRequest = !createsTask && !unmarshalRetried
+					unmarshalRetried = true
+				}
+			}
+		}
+		retryRequest = retryRequest && attempt+1 < policy.MaxAttempts
+		fasthttp.ReleaseRequest(request)
+		fasthttp.ReleaseResponse(response)
+
+		if !retryRequest {
+			break
+		}
+		if err := instance.sleep(ctx, policy.delay(attempt)); err != nil {
+			finalErr = err
+			break
+		}
+	}
 
-	return result
+	return finalErr
 }
 
 // NewInstance creates and populates a new Instance. If any error is encountered during
 // initialization, NewInstance returns an empty Instance and whatever error was found, else
-// it returns the populated instance and nil error.
+// it returns the populated instance and nil error. An empty apiKey is read from the
+// TWOCAPTCHA_API_KEY environment variable (APIKeyEnv).
 func NewInstance(apiKey string, settings SettingInfo) (instance Instance, finalErr error) {
+	return NewInstanceContext(context.Background(), apiKey, settings)
+}
+
+// NewInstanceContext creates a new Instance like NewInstance, giving up on checking the API key
+// with ctx.Err() once ctx is cancelled or its deadline passes.
+func NewInstanceContext(ctx context.Context, apiKey string, settings SettingInfo) (instance Instance, finalErr error) {
 OuterLoop:
 	for {
-		// Verify fields within Settings correctly inputted
-		if settings.TimeBetweenRequests <= 0 {
-			finalErr = errors.New("invalid setting TimeBetweenReqs value")
+		if apiKey == "" {
+			apiKey = os.Getenv(APIKeyEnv)
+		}
+		// Reject malformed keys before spending a request on them
+		if !validAPIKey(apiKey) {
+			finalErr = ErrInvalidAPIKey
 			break OuterLoop
 		}
 
-		instance.HTTPClient = &fasthttp.Client{}
-
-		var balRespStruct captchaResponse
-		requestURL := capResultURL + "&action=getBalance&key=" + apiKey
-		// Verify api key by checking remaining balance - don't do anything if balance empty
-		for retryRequest := true; retryRequest; {
-			request := fasthttp.AcquireRequest()
-			request.Header.SetMethod("GET")
-			request.SetRequestURI(requestURL)
-
-			response := fasthttp.AcquireResponse()
-			instance.HTTPClient.Do(request, response)
-			if checkResponse(response) {
-				if err := json.Unmarshal(response.Body(), &balRespStruct); err != nil {
-					finalErr = errorUnmarshal
-					fasthttp.ReleaseRequest(request)
-					fasthttp.ReleaseResponse(response)
-					break OuterLoop
+		// Verify fields within Settings correctly inputted
+		if settings.PollInterval < 0 {
+			finalErr = errors.New("invalid setting PollInterval value")
+			break OuterLoop
+		}
+		if settings.PollInterval == 0 {
+			settings.PollInterval = time.Second * time.Duration(settings.TimeBetweenRequests)
+		}
+		if settings.PollInterval <= 0 {
+			finalErr = errors.New("invalid setting TimeBetweenRequests value")
+			break OuterLoop
+		}
+		if settings.MaxPollInterval < 0 {
+			finalErr = errors.New("invalid setting MaxPollInterval value")
+			break OuterLoop
+		}
+		if settings.MaxSolveTime == 0 {
+			settings.MaxSolveTime = defaultMaxSolveTime
+		}
+		if settings.MaxSolveTime < 0 {
+			finalErr = errors.New("invalid setting MaxSolveTime value")
+			break OuterLoop
+		}
+		if settings.MaxSlotRetries < 0 {
+			finalErr = errors.New("invalid setting MaxSlotRetries value")
+			break OuterLoop
+		}
+		if settings.MaxResolves == 0 {
+			settings.MaxResolves = defaultMaxResolves
+		}
+		if settings.MaxResolves < 0 {
+			finalErr = errors.New("invalid setting MaxResolves value")
+			break OuterLoop
+		}
+		if !settings.RetryPolicy.valid() {
+			finalErr = errors.New("invalid setting RetryPolicy value")
+			break OuterLoop
+		}
+		settings.RetryPolicy = settings.RetryPolicy.withDefaults()
+		if settings.RequestTimeout == 0 {
+			settings.RequestTimeout = defaultHTTPTimeout
+		}
+		if settings.RequestTimeout < 0 {
+			finalErr = errors.New("invalid setting RequestTimeout value")
+			break OuterLoop
+		}
+		if settings.BaseURL != "" && !validBaseURL(settings.BaseURL) {
+			finalErr = ErrBaseURL
+			break OuterLoop
+		}
+		if settings.Proxy != "" && !validProxy(settings.Proxy) {
+			finalErr = errors.New("invalid setting Proxy value")
+			break OuterLoop
+		}
+		if len(settings.Proxies) > 0 {
+			var proxies []string
+			for _, proxy := range settings.Proxies {
+				if validProxy(proxy) {
+					proxies = append(proxies, proxy)
 				}
-				retryRequest = false
 			}
-			fasthttp.ReleaseRequest(request)
-			fasthttp.ReleaseResponse(response)
+			if len(proxies) == 0 {
+				finalErr = errors.New("invalid setting Proxies value")
+				break OuterLoop
+			}
+			settings.Proxies = proxies
 		}
-		if err := containsError(&balRespStruct); err != nil {
-			finalErr = err
+		if (settings.Proxy != "" || len(settings.Proxies) > 0) && !stringInSlice(validProxyTypes, settings.ProxyType) {
+			finalErr = ErrProxyType
 			break OuterLoop
 		}
 
-		instance.APIKey = apiKey
+		if settings.HTTPClient == nil {
+			settings.HTTPClient = defaultHTTPClient
+		}
+
+		instance.HTTPClient = settings.HTTPClient
 		instance.Settings = settings
+		instance.closed = new(int32)
+		instance.proxyIndex = new(uint32)
+		instance.pausedTill = new(int64)
+
+		// Verify api key by checking remaining balance - don't do anything if balance empty
+		if !settings.SkipKeyValidation {
+			if _, err := instance.getBalance(ctx, apiKey); err != nil {
+				finalErr = err
+				break OuterLoop
+			}
+		}
+
+		instance.APIKey = apiKey
 		break OuterLoop
 	}
 
 	return instance, finalErr
 }
 
-func (instance Instance) solveCaptcha(createTaskURL string) (solution string, finalErr error) {
+// sleep waits for duration using Settings.Sleep if set, giving up with ctx.Err() once ctx is
+// done
+func (instance *Instance) sleep(ctx context.Context, duration time.Duration) (finalErr error) {
+	if instance.Settings.Sleep != nil {
+		finalErr = instance.Settings.Sleep(ctx, duration)
+	} else {
+		finalErr = sleepContext(ctx, duration)
+	}
+
+	return finalErr
+}
+
+// String summarizes the instance for logs and debugging. The API key is masked.
+func (instance Instance) String() string {
+	return fmt.Sprintf("twocaptcha.Instance{APIKey: %s, BaseURL: %s, PollInterval: %s}",
+		maskAPIKey(instance.APIKey), instance.baseURL(), instance.Settings.PollInterval)
+}
+
+// MarshalJSON encodes the instance as a Config for config files. Secrets are masked rather than
+// stored: the API key as in String, and the login and password of Proxy and Proxies. Callbacks
+// and HTTP clients are left out. Instances aren't decoded directly: decode the Config and pass it
+// to NewInstanceFromConfig, supplying the API key and masked proxies again.
+func (instance Instance) MarshalJSON() ([]byte, error) {
+	var masked string
+	if instance.APIKey != "" {
+		masked = maskAPIKey(instance.APIKey)
+	}
+
+	settings := instance.Settings
+	settings.Proxy = maskProxy(settings.Proxy)
+	settings.Proxies = nil
+	for _, proxy := range instance.Settings.Proxies {
+		settings.Proxies = append(settings.Proxies, maskProxy(proxy))
+	}
+
+	return json.Marshal(Config{APIKey: masked, Settings: settings})
+}
+
+// Close marks the instance closed, after which all of its requests fail with ErrClosed, including
+// those of solves still polling. Copies of the instance are closed along with it. Close does not
+// release connections of the default HTTP client, which is shared by all instances and has no
+// way to close idle connections. Only a custom Doer that also has a CloseIdleConnections method
+// gets it called, closing idle connections for any other instance sharing that Doer too.
+func (instance *Instance) Close() {
+	if instance.closed != nil {
+		atomic.StoreInt32(instance.closed, 1)
+	}
+	if client, ok := instance.HTTPClient.(interface{ CloseIdleConnections() }); ok {
+		client.CloseIdleConnections()
+	}
+}
+
+func (instance *Instance) getBalance(ctx context.Context, apiKey string) (balance float64, finalErr error) {
 OuterLoop:
 	for {
-		var checkSolutionURL string
-		// Doing Atoi alot takes ... resources?
-		// - Maybe turn SettingInfo into interface{} vs string map
-		// - Remove SettingInfo and instead have each setting as a field
-		timeToSleep := time.Second * time.Duration(instance.Settings.TimeBetweenRequests)
+		var balRespStruct captchaResponse
+		requestURL := instance.capResultURL() + "&" + url.Values{"key": {apiKey}, "action": {"getBalance"}}.Encode()
+		if finalErr = instance.sendRequest(ctx, requestURL, nil, &balRespStruct); finalErr != nil {
+			break OuterLoop
+		}
+		if finalErr = containsError(&balRespStruct, "res"); finalErr != nil {
+			break OuterLoop
+		}
 
-	CreateTaskLoop:
+		balance, finalErr = parseBalance(balRespStruct.Response)
+		break OuterLoop
+	}
+
+	return balance, finalErr
+}
+
+// GetBalance returns the current balance of the account the instance's API key belongs to
+func (instance *Instance) GetBalance() (balance float64, finalErr error) {
+	return instance.GetBalanceContext(context.Background())
+}
+
+// GetBalanceContext returns the current balance like GetBalance, giving up with ctx.Err() once
+// ctx is cancelled or its deadline passes.
+func (instance *Instance) GetBalanceContext(ctx context.Context) (balance float64, finalErr error) {
+	return instance.getBalance(ctx, instance.APIKey)
+}
+
+// Ping checks that 2captcha is reachable and accepts the instance's API key by fetching the
+// balance, without creating a task. It returns nil when healthy, otherwise the request error or
+// an *APIError (e.g. matching ErrKeyDoesNotExist).
+func (instance *Instance) Ping() (finalErr error) {
+	return instance.PingContext(context.Background())
+}
+
+// PingContext checks 2captcha like Ping, giving up with ctx.Err() once ctx is cancelled or its
+// deadline passes.
+func (instance *Instance) PingContext(ctx context.Context) (finalErr error) {
+	_, finalErr = instance.getBalance(ctx, instance.APIKey)
+	return finalErr
+}
+
+// Stats contains the account's usage on one day, see GetStats
+type Stats struct {
+	Volume int         // captchas solved
+	Money  float64     // amount spent
+	Hours  []HourStats // the same, per hour of the day
+}
+
+// HourStats contains the account's usage in one hour of a day
+type HourStats struct {
+	Hour   int     `xml:"hour,attr"`
+	Volume int     `xml:"volume"`
+	Money  float64 `xml:"money"`
+}
+
+// GetStats returns the account's usage on the day of date (in 2captcha's time zone)
+func (instance *Instance) GetStats(date time.Time) (stats Stats, finalErr error) {
+	return instance.GetStatsContext(context.Background(), date)
+}
+
+// GetStatsContext returns the account's usage like GetStats, giving up with ctx.Err() once ctx
+// is cancelled or its deadline passes.
+func (instance *Instance) GetStatsContext(ctx context.Context, date time.Time) (stats Stats, finalErr error) {
+OuterLoop:
+	for {
+		var statsRespStruct captchaResponse
+		requestURL := instance.capResultURL() + "&" + url.Values{
+			"key": {instance.APIKey}, "action": {"getstats"}, "date": {date.Format("2006-01-02")},
+		}.Encode()
+		if finalErr = instance.sendRequest(ctx, requestURL, nil, &statsRespStruct); finalErr != nil {
+			break OuterLoop
+		}
+		if finalErr = containsError(&statsRespStruct, "res"); finalErr != nil {
+			break OuterLoop
+		}
+
+		stats, finalErr = parseStats(statsRespStruct.Response)
+		break OuterLoop
+	}
+
+	return stats, finalErr
+}
+
+func (instance *Instance) report(ctx context.Context, action string, taskID string) (finalErr error) {
+	var reportRespStruct captchaResponse
+	requestURL := instance.capResultURL() + "&" + url.Values{
+		"key": {instance.APIKey}, "action": {action}, "id": {taskID},
+	}.Encode()
+	if finalErr = instance.sendRequest(ctx, requestURL, nil, &reportRespStruct); finalErr == nil {
+		finalErr = containsError(&reportRespStruct, "res") // OK_REPORT_RECORDED on success
+	}
+
+	return finalErr
+}
+
+// ReportBad reports the solution of the task with taskID as incorrect, e.g. because the target
+// site rejected it. 2captcha refunds the captcha and uses the report to improve accuracy.
+func (instance *Instance) ReportBad(taskID string) (finalErr error) {
+	return instance.report(context.Background(), "reportbad", taskID)
+}
+
+// ReportGood reports the solution of the task with taskID as correct
+func (instance *Instance) ReportGood(taskID string) (finalErr error) {
+	return instance.report(context.Background(), "reportgood", taskID)
+}
+
+// CancelTask tells 2captcha the task with taskID is no longer needed. 2captcha can't cancel
+// tasks, so this reports the task as bad, which may get it refunded; a worker may still solve
+// it. Solves polling for the task aren't affected, cancel their context to stop them.
+func (instance *Instance) CancelTask(taskID string) (finalErr error) {
+	return instance.report(context.Background(), "reportbad", taskID)
+}
+
+// nextProxy returns the proxy to create the next task with: Settings.Proxies in turn if set,
+// else Settings.Proxy. The rotation only moves on when advance is set, so that previewing a
+// request doesn't skip a proxy.
+func (instance *Instance) nextProxy(advance bool) (proxy string) {
+	proxy = instance.Settings.Proxy
+	if proxies := instance.Settings.Proxies; len(proxies) > 0 {
+		proxy = proxies[0]
+		if instance.proxyIndex != nil {
+			index := atomic.LoadUint32(instance.proxyIndex)
+			if advance {
+				index = atomic.AddUint32(instance.proxyIndex, 1) - 1
+			}
+			proxy = proxies[index%uint32(len(proxies))]
+		}
+	}
+
+	return proxy
+}
+
+// createTaskForm returns the parameters sent to in.php to create task, including the API key
+// and any instance-wide settings that apply to the task's type. advance is passed to nextProxy.
+func (instance *Instance) createTaskForm(task Task, advance bool) (createTaskForm url.Values) {
+	createTaskForm = url.Values{"key": {instance.APIKey}}
+	for key, values := range task.Params {
+		createTaskForm[key] = values
+	}
+	// only tasks that can use it take a proxy from the rotation, so that none is skipped
+	if stringInSlice(proxyTypes, task.Type) {
+		if proxy := instance.nextProxy(advance); proxy != "" {
+			createTaskForm.Set("proxy", proxy)
+			createTaskForm.Set("proxytype", instance.Settings.ProxyType)
+		}
+	}
+	if instance.Settings.Pingback != "" {
+		createTaskForm.Set("pingback", instance.Settings.Pingback)
+	}
+	if instance.Settings.SoftID != "" {
+		createTaskForm.Set("soft_id", instance.Settings.SoftID)
+	}
+	if instance.Settings.HeaderACAO {
+		createTaskForm.Set("header_acao", "1")
+	}
+
+	return createTaskForm
+}
+
+// createTaskRequest validates task and builds the request creating it: a GET to createTaskURL
+// when createTaskForm is nil, else a POST of createTaskForm. Parameters are trimmed of the
+// whitespace pasted values often carry. advance is passed to nextProxy.
+func (instance *Instance) createTaskRequest(
+	task Task,
+	advance bool,
+) (createTaskURL string, createTaskForm url.Values, finalErr error) {
+	task.Params = trimParams(task.Params)
+	if finalErr = validateTask(task); finalErr == nil {
+		// GET unless the task needs a POST or its parameters would make the URL too long
+		createTaskForm = instance.createTaskForm(task, advance)
+		createTaskURL = instance.capRequestURL()
+		getURL := createTaskURL + "&" + createTaskForm.Encode()
+		if !task.usesPost() && len(getURL) <= maxGetURLLength {
+			createTaskURL = getURL
+			createTaskForm = nil
+		}
+	}
+
+	return createTaskURL, createTaskForm, finalErr
+}
+
+// BuildCreateRequest returns the request that creating task would send, without sending it, for
+// debugging integrations. postBody is empty for GET requests, otherwise the url-encoded form
+// POSTed to requestURL. Both include the instance's API key, so take care when logging them.
+// The proxy rotation isn't advanced: the request shows the proxy the next task would use.
+func (instance *Instance) BuildCreateRequest(task Task) (requestURL string, postBody string, finalErr error) {
+	requestURL, createTaskForm, finalErr := instance.createTaskRequest(task, false)
+	if createTaskForm != nil {
+		postBody = createTaskForm.Encode()
+	}
+
+	return requestURL, postBody, finalErr
+}
+
+// pauseUserTurn pauses task creation for userTurnPause after 2captcha answered MAX_USER_TURN.
+// The pause is shared by every solve on the instance and its copies, as the ban applies to the
+// whole account; instances not created by NewInstance only pause the current call.
+func (instance *Instance) pauseUserTurn(ctx context.Context) (finalErr error) {
+	if instance.pausedTill == nil {
+		finalErr = instance.sleep(ctx, userTurnPause)
+	} else {
+		pausedTill := time.Now().Add(userTurnPause).UnixNano()
+	OuterLoop:
 		for {
+			current := atomic.LoadInt64(instance.pausedTill)
+			if current >= pausedTill || atomic.CompareAndSwapInt64(instance.pausedTill, current, pausedTill) {
+				break OuterLoop
+			}
+		}
+	}
+
+	return finalErr
+}
+
+// waitUserTurn waits until the instance's MAX_USER_TURN pause, if any, is over
+func (instance *Instance) waitUserTurn(ctx context.Context) (finalErr error) {
+	if instance.pausedTill != nil {
+		if remaining := time.Until(time.Unix(0, atomic.LoadInt64(instance.pausedTill))); remaining > 0 {
+			finalErr = instance.sleep(ctx, remaining)
+		}
+	}
+
+	return finalErr
+}
+
+// createTask validates task and creates it with 2captcha, returning the task ID. Creation is
+// retried while no worker slot is available, and after a pause shared by all solves on the
+// instance when 2captcha answers MAX_USER_TURN, in both cases up to Settings.MaxSlotRetries
+// times. Other errors, such as IP_BANNED (ErrIPBanned), are returned right away.
+func (instance *Instance) createTask(ctx context.Context, task Task) (taskID string, finalErr error) {
+OuterLoop:
+	for {
+		createTaskURL, createTaskForm, err := instance.createTaskRequest(task, true)
+		if err != nil {
+			finalErr = err
+			break OuterLoop
+		}
+
+	CreateTaskLoop:
+		for slotRetries := 0; ; slotRetries++ {
+			if finalErr = instance.waitUserTurn(ctx); finalErr != nil {
+				break OuterLoop
+			}
+
 			var taskStruct captchaResponse
-			for retryRequest := true; retryRequest; {
-				request := fasthttp.AcquireRequest()
-				request.Header.SetMethod("GET")
-				request.SetRequestURI(createTaskURL)
-
-				response := fasthttp.AcquireResponse()
-				instance.HTTPClient.Do(request, response)
-				if checkResponse(response) {
-					if err := json.Unmarshal(response.Body(), &taskStruct); err != nil {
-						finalErr = errorUnmarshal
-						fasthttp.ReleaseRequest(request)
-						fasthttp.ReleaseResponse(response)
-						break OuterLoop
-					}
-					retryRequest = false
-				}
-				fasthttp.ReleaseRequest(request)
-				fasthttp.ReleaseResponse(response)
+			if err := instance.sendRequest(ctx, createTaskURL, createTaskForm, &taskStruct); err != nil {
+				finalErr = err
+				break OuterLoop
 			}
 
-			if err := containsError(&taskStruct); err != nil {
-				if err == errorNoSlot {
-					time.Sleep(timeToSleep)
+			if err := containsError(&taskStruct, "in"); err != nil {
+				// no free worker, or MAX_USER_TURN's temporary ban: wait and try again
+				if errors.Is(err, errorNoSlot) || errors.Is(err, ErrTooManyRequests) {
+					if maxRetries := instance.Settings.MaxSlotRetries; maxRetries > 0 && slotRetries >= maxRetries {
+						finalErr = err
+						if errors.Is(err, errorNoSlot) {
+							finalErr = ErrSlotRetries
+						}
+						break OuterLoop
+					}
+					if errors.Is(err, ErrTooManyRequests) {
+						finalErr = instance.pauseUserTurn(ctx)
+					} else {
+						finalErr = instance.sleep(ctx, instance.Settings.PollInterval)
+					}
+					if finalErr != nil {
+						break OuterLoop
+					}
 					continue CreateTaskLoop
 				}
 
@@ -125,97 +708,595 @@ OuterLoop:
 				break OuterLoop
 			}
 
-			captchaTaskID := taskStruct.Response // only includes task ID
-			checkSolutionURL = fmt.Sprintf(
-				"%s&key=%s&action=get&id=%s",
-				capResultURL, instance.APIKey, captchaTaskID,
-			)
+			taskID = taskStruct.Response // only includes task ID
+			break OuterLoop
+		}
+	}
 
-			break CreateTaskLoop
+	return taskID, finalErr
+}
+
+// getResult checks once whether the task with taskID is solved. ready is false, with a nil
+// error, while 2captcha is still working on it.
+func (instance *Instance) getResult(
+	ctx context.Context, taskID string,
+) (result SolveResult, ready bool, finalErr error) {
+OuterLoop:
+	for {
+		result.TaskID = taskID
+
+		var solutionStruct captchaResponse
+		checkSolutionForm := url.Values{"key": {instance.APIKey}, "action": {"get"}, "id": {taskID}}
+		if instance.Settings.HeaderACAO {
+			checkSolutionForm.Set("header_acao", "1")
+		}
+		checkSolutionURL := instance.capResultURL() + "&" + checkSolutionForm.Encode()
+		if finalErr = instance.sendRequest(ctx, checkSolutionURL, nil, &solutionStruct); finalErr != nil {
+			break OuterLoop
+		}
+		if err := containsError(&solutionStruct, "res"); err != nil {
+			if !errors.Is(err, errorNotReady) {
+				finalErr = err
+			}
+			break OuterLoop
 		}
 
+		result.Token = solutionStruct.Response
+		result.Extra = parseExtra(solutionStruct.Response)
+		ready = true
+		break OuterLoop
+	}
+
+	return result, ready, finalErr
+}
+
+// solveCaptcha creates the task with 2captcha, then polls for its solution. It gives up with
+// ErrSolveTimeout once Settings.MaxSolveTime has passed. Timeouts after the task was created
+// are returned as a *TimeoutError carrying its ID.
+func (instance Instance) solveCaptcha(parentCtx context.Context, task Task) (result SolveResult, finalErr error) {
+	startTime := time.Now()
+	ctx := parentCtx
+	if instance.Settings.MaxSolveTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parentCtx, instance.Settings.MaxSolveTime)
+		defer cancel()
+	}
+	polls, resolves := 0, 0
+OuterLoop:
+	for {
+		taskID, err := instance.createTask(ctx, task)
+		if err != nil {
+			finalErr = err
+			break OuterLoop
+		}
+		instance.emit(Event{Type: EventTaskCreated, CaptchaType: task.Type, TaskID: taskID})
+		result.TaskID = taskID
+
 	SolutionLoop:
-		for {
-			var solutionStruct captchaResponse
-			for retryRequest := true; retryRequest; {
-				request := fasthttp.AcquireRequest()
-				request.Header.SetMethod("GET")
-				request.SetRequestURI(checkSolutionURL)
-
-				response := fasthttp.AcquireResponse()
-				instance.HTTPClient.Do(request, response)
-				if checkResponse(response) {
-					if err := json.Unmarshal(response.Body(), &solutionStruct); err != nil {
-						finalErr = errorUnmarshal
-						fasthttp.ReleaseRequest(request)
-						fasthttp.ReleaseResponse(response)
-						break OuterLoop
-					}
-					retryRequest = false
+		for attempt := 1; ; attempt++ {
+			instance.emit(Event{Type: EventPoll, CaptchaType: task.Type, TaskID: taskID, Attempt: attempt})
+			polls++
+
+			var ready bool
+			if result, ready, finalErr = instance.getResult(ctx, taskID); finalErr != nil {
+				if errors.Is(finalErr, ErrCaptchaUnsolvable) && instance.Settings.ProxylessFallback &&
+					(instance.Settings.Proxy != "" || len(instance.Settings.Proxies) > 0) &&
+					stringInSlice(proxyTypes, task.Type) {
+					// try once more without the proxy, instance being this call's copy
+					instance.Settings.Proxy, instance.Settings.Proxies, finalErr = "", nil, nil
+					continue OuterLoop
 				}
-				fasthttp.ReleaseRequest(request)
-				fasthttp.ReleaseResponse(response)
+				break OuterLoop
 			}
-			if err := containsError(&solutionStruct); err != nil {
-				if err == errorNotReady {
-					time.Sleep(timeToSleep)
-					continue SolutionLoop
+			if !ready {
+				instance.emit(Event{Type: EventNotReady, CaptchaType: task.Type, TaskID: taskID, Attempt: attempt})
+				delay := pollDelay(instance.Settings.PollInterval, instance.Settings.MaxPollInterval, attempt)
+				if finalErr = instance.sleep(ctx, delay); finalErr != nil {
+					break OuterLoop
 				}
-
-				finalErr = err
-				break OuterLoop
+				continue SolutionLoop
 			}
 
-			solution = solutionStruct.Response
+			switch task.Type {
+			case "coordinates":
+				result.Coordinates, finalErr = parseCoordinates(result.Token)
+			case "boundingbox":
+				result.Boxes, finalErr = parseBoxes(result.Token)
+			}
+			validateToken := instance.Settings.ValidateToken
+			if finalErr == nil && validateToken != nil && !validateToken(result.Token) {
+				// the target site rejected the token, get a refund and solve again
+				_ = instance.report(ctx, "reportbad", taskID)
+				if resolves < instance.Settings.MaxResolves {
+					resolves++
+					continue OuterLoop
+				}
+				finalErr = ErrTokenRejected
+			}
 			break OuterLoop
 		}
 	}
+	if finalErr == context.DeadlineExceeded && parentCtx.Err() == nil {
+		finalErr = ErrSolveTimeout
+	}
+	if (finalErr == ErrSolveTimeout || finalErr == context.DeadlineExceeded) && result.TaskID != "" {
+		finalErr = &TimeoutError{TaskID: result.TaskID, err: finalErr}
+	}
+	if finalErr == nil && instance.Settings.RefreshBalance {
+		// the captcha is solved and paid for already, so a failed balance check doesn't fail it
+		result.Balance, _ = instance.getBalance(parentCtx, instance.APIKey)
+	}
+	result.Type = task.Type
+	result.Duration = time.Since(startTime)
+	result.Polls = polls
 
-	return solution, finalErr
+	if finalErr != nil {
+		instance.emit(Event{Type: EventError, CaptchaType: task.Type, TaskID: result.TaskID, Err: finalErr})
+	} else {
+		instance.emit(Event{Type: EventSolved, CaptchaType: task.Type, TaskID: result.TaskID, Result: result})
+	}
+	if instance.Settings.OnSolveComplete != nil {
+		instance.Settings.OnSolveComplete(result, finalErr)
+	}
+
+	return result, finalErr
 }
 
-// SolveRecaptchaV2 solves Google RecaptchaV2
-func (instance *Instance) SolveRecaptchaV2(sitekey string, siteurl string) (solution string, finalErr error) {
-	createTaskURL := fmt.Sprintf(
-		"%s&key=%s&method=userrecaptcha&googlekey=%s&pageurl=%s",
-		capRequestURL, instance.APIKey, sitekey, siteurl,
-	)
+// SolveTask solves any supported captcha described by task and returns the full SolveResult.
+// The Solve* methods for individual captcha types are shorthands for this. The result's TaskID
+// is set whenever the task was created, even if solving it failed, so it can be passed to
+// ReportBad or ReportGood.
+func (instance *Instance) SolveTask(task Task) (result SolveResult, finalErr error) {
+	return instance.SolveTaskContext(context.Background(), task)
+}
 
-	solution, finalErr = instance.solveCaptcha(createTaskURL)
+// SolveTaskContext solves the captcha described by task, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveTaskContext(ctx context.Context, task Task) (result SolveResult, finalErr error) {
+	return instance.solveCaptcha(ctx, task)
+}
 
-	return solution, finalErr
+// SolveTaskStream solves task in the background and sends each step of solving it on the
+// returned channel, which is closed once solving ends. The last event is EventSolved, carrying
+// the result, or EventError. The channel must be drained until closed; once ctx is done,
+// remaining events are dropped. Settings.OnEvent is still called for every event.
+func (instance *Instance) SolveTaskStream(ctx context.Context, task Task) <-chan Event {
+	events := make(chan Event)
+	streamInstance := *instance
+	onEvent := instance.Settings.OnEvent
+	streamInstance.Settings.OnEvent = func(event Event) {
+		if onEvent != nil {
+			onEvent(event)
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(events)
+		_, _ = streamInstance.solveCaptcha(ctx, task)
+	}()
+
+	return events
 }
 
-// SolveRecaptchaV3 solves Google RecaptchaV3
-func (instance *Instance) SolveRecaptchaV3(
-	sitekey string, siteurl string, action string, minScore string,
-) (solution string, finalErr error) {
+// SolveMany solves all tasks concurrently, e.g. several tokens for the same captcha, and
+// returns their results in the order of tasks. If any task fails the others are cancelled and
+// the first error is returned.
+func (instance *Instance) SolveMany(ctx context.Context, tasks []Task) (results []SolveResult, finalErr error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results = make([]SolveResult, len(tasks))
+	var waitGroup sync.WaitGroup
+	var errOnce sync.Once
+	for index, task := range tasks {
+		waitGroup.Add(1)
+		go func(index int, task Task) {
+			defer waitGroup.Done()
+			var err error
+			if results[index], err = instance.solveCaptcha(ctx, task); err != nil {
+				errOnce.Do(func() {
+					finalErr = err
+					cancel()
+				})
+			}
+		}(index, task)
+	}
+	waitGroup.Wait()
+
+	return results, finalErr
+}
+
+// SubmitTask creates the task with 2captcha and returns its ID without waiting for the
+// solution. Use it with the Pingback setting to have 2captcha send the solution to your server
+// instead of polling for it (see ParsePingback).
+func (instance *Instance) SubmitTask(task Task) (taskID string, finalErr error) {
+	return instance.SubmitTaskContext(context.Background(), task)
+}
+
+// SubmitTaskContext creates the task with 2captcha like SubmitTask, giving up with ctx.Err()
+// once ctx is cancelled or its deadline passes.
+func (instance *Instance) SubmitTaskContext(ctx context.Context, task Task) (taskID string, finalErr error) {
+	return instance.createTask(ctx, task)
+}
+
+// GetResult checks once whether the task with taskID (as returned by SubmitTask) is solved,
+// for callers running their own polling loop. ready is false, with a nil error, while 2captcha
+// is still working on the task. Type and Duration are not set on the returned result.
+func (instance *Instance) GetResult(taskID string) (result SolveResult, ready bool, finalErr error) {
+	return instance.GetResultContext(context.Background(), taskID)
+}
+
+// GetResultContext checks once whether the task with taskID is solved like GetResult, giving
+// up with ctx.Err() once ctx is cancelled or its deadline passes.
+func (instance *Instance) GetResultContext(
+	ctx context.Context, taskID string,
+) (result SolveResult, ready bool, finalErr error) {
+	return instance.getResult(ctx, taskID)
+}
+
+// ParsePingback parses the url-encoded body 2captcha POSTs to the pingback URL once a task is
+// solved, returning the task ID and solution.
+func ParsePingback(body []byte) (result SolveResult, finalErr error) {
 OuterLoop:
 	for {
-		if !stringInSlice(validV3Scores, minScore) {
-			finalErr = errors.New("invalid recaptchaV3 minScore (.1/.3/.9)")
+		payload, err := url.ParseQuery(string(body))
+		if err != nil {
+			finalErr = err
+			break OuterLoop
+		}
+		if result.TaskID = payload.Get("id"); result.TaskID == "" {
+			finalErr = ErrPingbackPayload
 			break OuterLoop
 		}
 
-		createTaskURL := fmt.Sprintf(
-			"%s&key=%s&method=userrecaptcha&version=v3&googlekey=%s&pageurl=%s&action=%s&min_score=%s",
-			capRequestURL, instance.APIKey, sitekey, siteurl, action, minScore,
-		)
+		result.Token = payload.Get("code")
+		result.Extra = parseExtra(result.Token)
+		break OuterLoop
+	}
+
+	return result, finalErr
+}
+
+// SolveRecaptchaV2 solves Google RecaptchaV2. Pass Invisible() for invisible reCAPTCHA widgets,
+// and Enterprise() or DataS() where the widget requires them.
+func (instance *Instance) SolveRecaptchaV2(
+	sitekey string, siteurl string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	return instance.SolveRecaptchaV2Context(context.Background(), sitekey, siteurl, opts...)
+}
+
+// SolveRecaptchaV2Context solves Google RecaptchaV2, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveRecaptchaV2Context(
+	ctx context.Context, sitekey string, siteurl string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewRecaptchaV2Task(sitekey, siteurl, opts...))
 
-		solution, finalErr = instance.solveCaptcha(createTaskURL)
+	return result.Token, finalErr
+}
+
+// SolveRecaptchaV3 solves Google RecaptchaV3. minScore is sent to 2captcha as min_score and
+// must be between 0.1 and 0.9, e.g. "0.3". Enterprise() and DataS() are accepted as options.
+func (instance *Instance) SolveRecaptchaV3(
+	sitekey string, siteurl string, action string, minScore string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	return instance.SolveRecaptchaV3Context(context.Background(), sitekey, siteurl, action, minScore, opts...)
+}
+
+// SolveRecaptchaV3Context solves Google RecaptchaV3, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveRecaptchaV3Context(
+	ctx context.Context, sitekey string, siteurl string, action string, minScore string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	task := NewRecaptchaV3Task(sitekey, siteurl, action, minScore, opts...)
+	result, finalErr := instance.solveCaptcha(ctx, task)
+
+	return result.Token, finalErr
+}
+
+// SolveFuncaptcha solves Arkose Funcaptcha. sitekey is the widget's public key (sent to 2captcha
+// as publickey) and surl the Arkose service URL, if known. Pass UserAgent() to solve with the
+// browser's User-Agent, and Blob() for challenges loaded with a data blob.
+func (instance *Instance) SolveFuncaptcha(
+	sitekey string, surl string, siteurl string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	return instance.SolveFuncaptchaContext(context.Background(), sitekey, surl, siteurl, opts...)
+}
+
+// SolveFuncaptchaContext solves Arkose Funcaptcha, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveFuncaptchaContext(
+	ctx context.Context, sitekey string, surl string, siteurl string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewFuncaptchaTask(sitekey, surl, siteurl, opts...))
+
+	return result.Token, finalErr
+}
+
+// SolveHCaptcha solves hCaptcha. Pass Invisible() for invisible widgets and RqData() where the
+// widget is rendered with rqdata.
+func (instance *Instance) SolveHCaptcha(
+	sitekey string, siteurl string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	return instance.SolveHCaptchaContext(context.Background(), sitekey, siteurl, opts...)
+}
+
+// SolveHCaptchaContext solves hCaptcha, giving up with ctx.Err() once ctx is cancelled or its
+// deadline passes.
+func (instance *Instance) SolveHCaptchaContext(
+	ctx context.Context, sitekey string, siteurl string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewHCaptchaTask(sitekey, siteurl, opts...))
+
+	return result.Token, finalErr
+}
+
+// SolveTurnstile solves Cloudflare Turnstile. Pass CData() and PageData() where the widget is
+// rendered with them.
+func (instance *Instance) SolveTurnstile(
+	sitekey string, siteurl string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	return instance.SolveTurnstileContext(context.Background(), sitekey, siteurl, opts...)
+}
+
+// SolveTurnstileContext solves Cloudflare Turnstile, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveTurnstileContext(
+	ctx context.Context, sitekey string, siteurl string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewTurnstileTask(sitekey, siteurl, opts...))
+
+	return result.Token, finalErr
+}
+
+// SolveAmazonWAF solves an Amazon WAF captcha, where sitekey, iv and wafContext are the key, iv
+// and context values found on the captcha page
+func (instance *Instance) SolveAmazonWAF(
+	sitekey string, iv string, wafContext string, siteurl string,
+) (solution string, finalErr error) {
+	return instance.SolveAmazonWAFContext(context.Background(), sitekey, iv, wafContext, siteurl)
+}
+
+// SolveAmazonWAFContext solves an Amazon WAF captcha, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveAmazonWAFContext(
+	ctx context.Context, sitekey string, iv string, wafContext string, siteurl string,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewAmazonWAFTask(sitekey, iv, wafContext, siteurl))
+
+	return result.Token, finalErr
+}
+
+// SolveCyberSiARA solves CyberSiARA, where masterURLID is the MasterUrlId found on the page and
+// userAgent the User-Agent of the browser the token will be used in
+func (instance *Instance) SolveCyberSiARA(
+	masterURLID string, siteurl string, userAgent string,
+) (solution string, finalErr error) {
+	return instance.SolveCyberSiARAContext(context.Background(), masterURLID, siteurl, userAgent)
+}
+
+// SolveCyberSiARAContext solves CyberSiARA, giving up with ctx.Err() once ctx is cancelled or its
+// deadline passes.
+func (instance *Instance) SolveCyberSiARAContext(
+	ctx context.Context, masterURLID string, siteurl string, userAgent string,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewCyberSiARATask(masterURLID, siteurl, userAgent))
+
+	return result.Token, finalErr
+}
+
+// ImageOptions contains optional hints passed to 2captcha alongside a normal image captcha.
+// Zero values are not sent.
+type ImageOptions struct {
+	Phrase        bool // answer contains at least two words
+	CaseSensitive bool // answer is case sensitive
+	Calc          bool // answer is the result of a calculation shown in the image
+	Numeric       int  // 1: digits only, 2: letters only, 3: digits or letters, 4: digits and letters
+	MinLength     int
+	MaxLength     int
+}
+
+// SolveImage solves a normal image captcha, where body is the base64 encoded image. The
+// recognized text is returned as the solution. Lang() and Language() are accepted as options.
+func (instance *Instance) SolveImage(
+	body string, options ImageOptions, opts ...TaskOption,
+) (solution string, finalErr error) {
+	return instance.SolveImageContext(context.Background(), body, options, opts...)
+}
+
+// SolveImageContext solves a normal image captcha, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveImageContext(
+	ctx context.Context, body string, options ImageOptions, opts ...TaskOption,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewImageTask(body, options, opts...))
+
+	return result.Token, finalErr
+}
+
+// SolveText solves a text captcha, returning the worker's answer to the question in text
+func (instance *Instance) SolveText(text string, opts ...TaskOption) (solution string, finalErr error) {
+	return instance.SolveTextContext(context.Background(), text, opts...)
+}
+
+// SolveTextContext solves a text captcha, giving up with ctx.Err() once ctx is cancelled or its
+// deadline passes.
+func (instance *Instance) SolveTextContext(
+	ctx context.Context, text string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewTextTask(text, opts...))
+
+	return result.Token, finalErr
+}
+
+// SolveRotate solves a rotate captcha, where body is the base64 encoded image. The solution is
+// the angle in degrees the image has to be rotated by.
+func (instance *Instance) SolveRotate(body string, opts ...TaskOption) (solution string, finalErr error) {
+	return instance.SolveRotateContext(context.Background(), body, opts...)
+}
+
+// SolveRotateContext solves a rotate captcha, giving up with ctx.Err() once ctx is cancelled or
+// its deadline passes.
+func (instance *Instance) SolveRotateContext(
+	ctx context.Context, body string, opts ...TaskOption,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewRotateTask(body, opts...))
+
+	return result.Token, finalErr
+}
+
+// SolveAudio solves an audio captcha, where body is the base64 encoded MP3 file and lang the
+// language spoken. The transcription is returned as the solution.
+func (instance *Instance) SolveAudio(body string, lang string) (solution string, finalErr error) {
+	return instance.SolveAudioContext(context.Background(), body, lang)
+}
+
+// SolveAudioContext solves an audio captcha, giving up with ctx.Err() once ctx is cancelled or
+// its deadline passes.
+func (instance *Instance) SolveAudioContext(
+	ctx context.Context, body string, lang string,
+) (solution string, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewAudioTask(body, lang))
+
+	return result.Token, finalErr
+}
+
+// SolveCoordinates solves a coordinates captcha, where body is the base64 encoded image, and
+// returns the points to click. TextInstructions() is accepted as an option.
+func (instance *Instance) SolveCoordinates(body string, opts ...TaskOption) (points []Point, finalErr error) {
+	return instance.SolveCoordinatesContext(context.Background(), body, opts...)
+}
+
+// SolveCoordinatesContext solves a coordinates captcha, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveCoordinatesContext(
+	ctx context.Context, body string, opts ...TaskOption,
+) (points []Point, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewCoordinatesTask(body, opts...))
+
+	return result.Coordinates, finalErr
+}
+
+// SolveBoundingBox solves a bounding box captcha, where body is the base64 encoded image and
+// instructions tell the worker what to draw boxes around. The boxes drawn are returned.
+func (instance *Instance) SolveBoundingBox(body string, instructions string) (boxes []Rectangle, finalErr error) {
+	return instance.SolveBoundingBoxContext(context.Background(), body, instructions)
+}
+
+// SolveBoundingBoxContext solves a bounding box captcha, giving up with ctx.Err() once ctx is
+// cancelled or its deadline passes.
+func (instance *Instance) SolveBoundingBoxContext(
+	ctx context.Context, body string, instructions string,
+) (boxes []Rectangle, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewBoundingBoxTask(body, instructions))
+
+	return result.Boxes, finalErr
+}
+
+// GeeTestSolution contains the three values 2captcha returns for a solved GeeTest captcha,
+// which are submitted back to the target site in place of the user's answer.
+type GeeTestSolution struct {
+	Challenge string
+	Validate  string
+	Seccode   string
+}
+
+// SolveGeeTest solves GeeTest (v3). gt and challenge are taken from the page, apiServer is the
+// GeeTest API domain the page loads from, or empty if unknown.
+func (instance *Instance) SolveGeeTest(
+	gt string, challenge string, apiServer string, siteurl string,
+) (solution GeeTestSolution, finalErr error) {
+	return instance.SolveGeeTestContext(context.Background(), gt, challenge, apiServer, siteurl)
+}
+
+// SolveGeeTestContext solves GeeTest (v3), giving up with ctx.Err() once ctx is cancelled or
+// its deadline passes.
+func (instance *Instance) SolveGeeTestContext(
+	ctx context.Context, gt string, challenge string, apiServer string, siteurl string,
+) (solution GeeTestSolution, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewGeeTestTask(gt, challenge, apiServer, siteurl))
+	solution = GeeTestSolution{
+		Challenge: result.Extra["geetest_challenge"],
+		Validate:  result.Extra["geetest_validate"],
+		Seccode:   result.Extra["geetest_seccode"],
 	}
 
 	return solution, finalErr
 }
 
-// SolveFuncaptcha solves Arkose Funcaptcha
-func (instance *Instance) SolveFuncaptcha(sitekey string, surl string, siteurl string) (solution string, finalErr error) {
-	createTaskURL := fmt.Sprintf(
-		"%s&key=%s&method=funcaptcha&publickey=%s&surl=%s&pageurl=%s",
-		capRequestURL, instance.APIKey, sitekey, surl, siteurl,
-	)
+// GeeTestV4Solution contains the values 2captcha returns for a solved GeeTest v4 captcha
+type GeeTestV4Solution struct {
+	CaptchaID     string
+	LotNumber     string
+	PassToken     string
+	GenTime       string
+	CaptchaOutput string
+}
+
+// SolveGeeTestV4 solves GeeTest v4, where captchaID is the captcha_id the page initializes
+// GeeTest with
+func (instance *Instance) SolveGeeTestV4(captchaID string, siteurl string) (solution GeeTestV4Solution, finalErr error) {
+	return instance.SolveGeeTestV4Context(context.Background(), captchaID, siteurl)
+}
 
-	solution, finalErr = instance.solveCaptcha(createTaskURL)
+// SolveGeeTestV4Context solves GeeTest v4, giving up with ctx.Err() once ctx is cancelled or its
+// deadline passes.
+func (instance *Instance) SolveGeeTestV4Context(
+	ctx context.Context, captchaID string, siteurl string,
+) (solution GeeTestV4Solution, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewGeeTestV4Task(captchaID, siteurl))
+	solution = GeeTestV4Solution{
+		CaptchaID:     result.Extra["captcha_id"],
+		LotNumber:     result.Extra["lot_number"],
+		PassToken:     result.Extra["pass_token"],
+		GenTime:       result.Extra["gen_time"],
+		CaptchaOutput: result.Extra["captcha_output"],
+	}
 
 	return solution, finalErr
 }
+
+// CapySolution contains the values 2captcha returns for a solved Capy Puzzle captcha
+type CapySolution struct {
+	CaptchaKey   string
+	ChallengeKey string
+	Answer       string
+}
+
+// SolveCapy solves Capy Puzzle, where captchakey is the Capy site key found on the page
+func (instance *Instance) SolveCapy(captchakey string, siteurl string) (solution CapySolution, finalErr error) {
+	return instance.SolveCapyContext(context.Background(), captchakey, siteurl)
+}
+
+// SolveCapyContext solves Capy Puzzle, giving up with ctx.Err() once ctx is cancelled or its
+// deadline passes.
+func (instance *Instance) SolveCapyContext(
+	ctx context.Context, captchakey string, siteurl string,
+) (solution CapySolution, finalErr error) {
+	result, finalErr := instance.solveCaptcha(ctx, NewCapyTask(captchakey, siteurl))
+	solution = CapySolution{
+		CaptchaKey:   result.Extra["captchakey"],
+		ChallengeKey: result.Extra["challengekey"],
+		Answer:       result.Extra["answer"],
+	}
+
+	return solution, finalErr
+}
+
+// SolveGeneric solves a captcha of any 2captcha method, see NewGenericTask. The full result is
+// returned since the shape of the solution depends on the method.
+func (instance *Instance) SolveGeneric(method string, params map[string]string) (result SolveResult, finalErr error) {
+	return instance.SolveGenericContext(context.Background(), method, params)
+}
+
+// SolveGenericContext solves a captcha of any 2captcha method, giving up with ctx.Err() once ctx
+// is cancelled or its deadline passes.
+func (instance *Instance) SolveGenericContext(
+	ctx context.Context, method string, params map[string]string,
+) (result SolveResult, finalErr error) {
+	return instance.solveCaptcha(ctx, NewGenericTask(method, params))
+}
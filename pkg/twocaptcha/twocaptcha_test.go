@@ -0,0 +1,633 @@
+package twocaptcha
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const testAPIKey = "0123456789abcdef0123456789ABCDEF"
+
+// fakeDoer is an example Doer answering requests like 2captcha would, without network access.
+// respond picks the answer to each request; every request is recorded. It is safe for
+// concurrent use, so instances may share it.
+type fakeDoer struct {
+	respond func(request fakeRequest) (statusCode int, body string, err error)
+
+	mutex    sync.Mutex
+	requests []fakeRequest
+}
+
+// fakeRequest is a request received by fakeDoer. Endpoint is "in" or "res", Action the res.php
+// action, and Params the query and form parameters sent.
+type fakeRequest struct {
+	Method   string
+	Endpoint string
+	Action   string
+	Params   url.Values
+}
+
+func (doer *fakeDoer) Do(request *fasthttp.Request, response *fasthttp.Response) error {
+	params, _ := url.ParseQuery(string(request.URI().QueryString()))
+	if form, err := url.ParseQuery(string(request.Body())); err == nil {
+		for key, values := range form {
+			params[key] = append(params[key], values...)
+		}
+	}
+	received := fakeRequest{Method: string(request.Header.Method()), Endpoint: "res", Params: params}
+	if strings.Contains(string(request.URI().Path()), "in.php") {
+		received.Endpoint = "in"
+	}
+	received.Action = params.Get("action")
+
+	doer.mutex.Lock()
+	doer.requests = append(doer.requests, received)
+	doer.mutex.Unlock()
+
+	statusCode, body, err := doer.respond(received)
+	response.SetStatusCode(statusCode)
+	response.SetBodyString(body)
+
+	return err
+}
+
+func (doer *fakeDoer) DoDeadline(request *fasthttp.Request, response *fasthttp.Response, _ time.Time) error {
+	return doer.Do(request, response)
+}
+
+// received returns the requests made to endpoint, to res.php only those with action
+func (doer *fakeDoer) received(endpoint string, action string) (requests []fakeRequest) {
+	doer.mutex.Lock()
+	defer doer.mutex.Unlock()
+
+	for _, request := range doer.requests {
+		if request.Endpoint == endpoint && (endpoint == "in" || request.Action == action) {
+			requests = append(requests, request)
+		}
+	}
+
+	return requests
+}
+
+// answering returns a fakeDoer answering every request with status 200 and body
+func answering(body string) *fakeDoer {
+	return &fakeDoer{respond: func(fakeRequest) (int, string, error) {
+		return 200, body, nil
+	}}
+}
+
+// solvingDoer returns a fakeDoer creating task "42" and answering polls with token once
+// notReady polls were answered CAPCHA_NOT_READY
+func solvingDoer(token string, notReady int32) *fakeDoer {
+	var polls int32
+	return &fakeDoer{respond: func(request fakeRequest) (int, string, error) {
+		switch {
+		case request.Endpoint == "in":
+			return 200, `{"status":1,"request":"42"}`, nil
+		case request.Action == "get" && atomic.AddInt32(&polls, 1) <= notReady:
+			return 200, `{"status":0,"request":"CAPCHA_NOT_READY"}`, nil
+		case request.Action == "get":
+			return 200, `{"status":1,"request":"` + token + `"}`, nil
+		}
+		return 200, `{"status":1,"request":"OK_REPORT_RECORDED"}`, nil
+	}}
+}
+
+// newTestInstance creates an instance talking to doer, waiting only a millisecond between
+// requests so that tests run quickly
+func newTestInstance(t *testing.T, doer Doer, options ...Option) Instance {
+	t.Helper()
+
+	options = append([]Option{
+		WithHTTPClient(doer),
+		WithSkipKeyValidation(),
+		WithPollInterval(time.Second),
+		WithSleep(func(ctx context.Context, _ time.Duration) error {
+			return sleepContext(ctx, time.Millisecond)
+		}),
+	}, options...)
+	instance, err := NewInstanceWithOptions(testAPIKey, options...)
+	if err != nil {
+		t.Fatalf("NewInstanceWithOptions: %v", err)
+	}
+
+	return instance
+}
+
+func TestRequestsGoThroughDoer(t *testing.T) {
+	doer := answering(`{"status":1,"request":"1.5"}`)
+	instance := newTestInstance(t, doer)
+
+	if balance, err := instance.GetBalance(); err != nil || balance != 1.5 {
+		t.Fatalf("GetBalance = %v, %v, want 1.5, nil", balance, err)
+	}
+	requests := doer.received("res", "getBalance")
+	if len(requests) != 1 || requests[0].Params.Get("key") != testAPIKey {
+		t.Errorf("requests = %+v, want one getBalance request with the API key", requests)
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	var failures int32 = 2
+	doer := &fakeDoer{respond: func(fakeRequest) (int, string, error) {
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			return 502, "<html>Bad Gateway</html>", nil
+		}
+		return 200, `{"status":1,"request":"1.5"}`, nil
+	}}
+	instance := newTestInstance(t, doer)
+
+	if balance, err := instance.GetBalance(); err != nil || balance != 1.5 {
+		t.Fatalf("GetBalance = %v, %v, want 1.5, nil", balance, err)
+	}
+	if requests := len(doer.received("res", "getBalance")); requests != 3 {
+		t.Errorf("sent %d requests, want 3", requests)
+	}
+
+	atomic.StoreInt32(&failures, 100)
+	if _, err := instance.GetBalance(); !errors.Is(err, ErrHTTPStatus) {
+		t.Errorf("GetBalance error = %v, want ErrHTTPStatus", err)
+	}
+	if requests := len(doer.received("res", "getBalance")); requests != 3+defaultMaxAttempts {
+		t.Errorf("sent %d requests, want %d", requests, 3+defaultMaxAttempts)
+	}
+}
+
+func TestCreateRetriedOnlyWhenNotSent(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		requests int
+	}{
+		{"dial error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, defaultMaxAttempts},
+		{"read timeout", fasthttp.ErrTimeout, 1},
+		{"connection closed", fasthttp.ErrConnectionClosed, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doer := &fakeDoer{respond: func(fakeRequest) (int, string, error) {
+				return 200, "", test.err
+			}}
+			instance := newTestInstance(t, doer)
+
+			if _, err := instance.SubmitTask(NewHCaptchaTask("sitekey", "https://example.com/")); err == nil {
+				t.Fatal("SubmitTask succeeded, want error")
+			}
+			if requests := len(doer.received("in", "")); requests != test.requests {
+				t.Errorf("sent %d create requests, want %d", requests, test.requests)
+			}
+		})
+	}
+}
+
+func TestUnreadableCreateResponseNotRetried(t *testing.T) {
+	for _, body := range []string{`{"status":1,"request":"4`, ""} {
+		doer := answering(body)
+		instance := newTestInstance(t, doer)
+
+		if _, err := instance.SubmitTask(NewHCaptchaTask("sitekey", "https://example.com/")); err == nil {
+			t.Fatalf("SubmitTask answered %q succeeded, want error", body)
+		}
+		if requests := len(doer.received("in", "")); requests != 1 {
+			t.Errorf("answered %q, sent %d create requests, want 1", body, requests)
+		}
+	}
+
+	doer := answering("Bad Gateway")
+	instance := newTestInstance(t, doer)
+	if _, err := instance.GetBalance(); !errors.Is(err, ErrUnmarshal) || !strings.Contains(err.Error(), "Bad Gateway") {
+		t.Errorf("GetBalance error = %v, want ErrUnmarshal quoting the body", err)
+	}
+	if requests := len(doer.received("res", "getBalance")); requests != 2 {
+		t.Errorf("sent %d getBalance requests, want 2", requests)
+	}
+}
+
+func TestProxyRotation(t *testing.T) {
+	doer := solvingDoer("token", 0)
+	instance := newTestInstance(t, doer, WithProxies([]string{"1.2.3.4:1", "bad", "user:pass@1.2.3.4:2"}, "HTTP"))
+
+	tasks := []Task{
+		NewHCaptchaTask("sitekey", "https://example.com/"),
+		NewImageTask("aGVsbG8=", ImageOptions{}),
+		NewHCaptchaTask("sitekey", "https://example.com/"),
+		NewHCaptchaTask("sitekey", "https://example.com/"),
+	}
+	for _, task := range tasks {
+		if _, _, err := instance.BuildCreateRequest(task); err != nil {
+			t.Fatalf("BuildCreateRequest(%s): %v", task.Type, err)
+		}
+		if _, err := instance.SolveTask(task); err != nil {
+			t.Fatalf("SolveTask(%s): %v", task.Type, err)
+		}
+	}
+
+	var proxies []string
+	for _, request := range doer.received("in", "") {
+		proxies = append(proxies, request.Params.Get("proxy"))
+	}
+	want := []string{"1.2.3.4:1", "", "user:pass@1.2.3.4:2", "1.2.3.4:1"}
+	if strings.Join(proxies, ",") != strings.Join(want, ",") {
+		t.Errorf("proxies = %q, want %q", proxies, want)
+	}
+
+	if _, err := NewInstanceWithOptions(testAPIKey, WithSkipKeyValidation(), WithProxy("1.2.3.4", "HTTP")); err == nil {
+		t.Error("NewInstanceWithOptions with a proxy without port succeeded, want error")
+	}
+}
+
+func TestStringMasksAPIKey(t *testing.T) {
+	instance := newTestInstance(t, answering(""))
+
+	if text := instance.String(); strings.Contains(text, testAPIKey) || !strings.Contains(text, "****CDEF") {
+		t.Errorf("String() = %s, want the API key masked but its last 4 characters", text)
+	}
+	for apiKey, want := range map[string]string{"": "", "abcd": "****", "abcdef": "**cdef"} {
+		if masked := maskAPIKey(apiKey); masked != want {
+			t.Errorf("maskAPIKey(%q) = %q, want %q", apiKey, masked, want)
+		}
+	}
+}
+
+func TestSolveReturnsOnceSolved(t *testing.T) {
+	doer := solvingDoer("token", 2)
+	instance := newTestInstance(t, doer)
+
+	done := make(chan error, 1)
+	go func() {
+		solution, err := instance.SolveRecaptchaV3("sitekey", "https://example.com/", "verify", "0.3")
+		if err == nil && solution != "token" {
+			err = errors.New("unexpected solution " + solution)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("SolveRecaptchaV3: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SolveRecaptchaV3 did not return after the captcha was solved")
+	}
+	if created := len(doer.received("in", "")); created != 1 {
+		t.Errorf("created %d tasks, want 1", created)
+	}
+	if polls := len(doer.received("res", "get")); polls != 3 {
+		t.Errorf("polled %d times, want 3", polls)
+	}
+}
+
+func TestImagePosted(t *testing.T) {
+	doer := solvingDoer("text", 0)
+	instance := newTestInstance(t, doer)
+
+	if solution, err := instance.SolveImage("aGVsbG8=", ImageOptions{}); err != nil || solution != "text" {
+		t.Fatalf("SolveImage = %q, %v, want text, nil", solution, err)
+	}
+	requests := doer.received("in", "")
+	if len(requests) != 1 || requests[0].Method != "POST" {
+		t.Fatalf("create requests = %+v, want one POST", requests)
+	}
+	if params := requests[0].Params; params.Get("method") != "base64" || params.Get("body") != "aGVsbG8=" {
+		t.Errorf("create params = %v, want method=base64 and the image body", params)
+	}
+}
+
+func TestReports(t *testing.T) {
+	doer := answering(`{"status":1,"request":"OK_REPORT_RECORDED"}`)
+	instance := newTestInstance(t, doer)
+
+	if err := instance.ReportBad("42"); err != nil {
+		t.Errorf("ReportBad: %v", err)
+	}
+	if err := instance.ReportGood("43"); err != nil {
+		t.Errorf("ReportGood: %v", err)
+	}
+	for action, taskID := range map[string]string{"reportbad": "42", "reportgood": "43"} {
+		requests := doer.received("res", action)
+		if len(requests) != 1 || requests[0].Params.Get("id") != taskID || requests[0].Params.Get("key") != testAPIKey {
+			t.Errorf("%s requests = %+v, want one for task %s with the API key", action, requests, taskID)
+		}
+	}
+
+	instance = newTestInstance(t, answering(`{"status":0,"request":"ERROR_WRONG_CAPTCHA_ID"}`))
+	if err := instance.ReportBad("42"); !errors.Is(err, ErrWrongCaptchaID) {
+		t.Errorf("ReportBad error = %v, want ErrWrongCaptchaID", err)
+	}
+}
+
+func TestSolveTimeout(t *testing.T) {
+	instance := newTestInstance(t, solvingDoer("token", 1<<30), WithMaxSolveTime(50*time.Millisecond))
+
+	if _, err := instance.SolveTask(NewHCaptchaTask("sitekey", "https://example.com/")); !errors.Is(err, ErrSolveTimeout) {
+		t.Errorf("SolveTask error = %v, want ErrSolveTimeout", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := instance.SolveTaskContext(ctx, NewHCaptchaTask("sitekey", "https://example.com/"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SolveTaskContext error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestParsePingback(t *testing.T) {
+	result, err := ParsePingback([]byte("id=42&code=03AGdBq2"))
+	if err != nil || result.TaskID != "42" || result.Token != "03AGdBq2" {
+		t.Errorf("ParsePingback = %+v, %v, want task 42 with its token", result, err)
+	}
+
+	if _, err := ParsePingback([]byte("code=03AGdBq2")); !errors.Is(err, ErrPingbackPayload) {
+		t.Errorf("ParsePingback without id: error = %v, want ErrPingbackPayload", err)
+	}
+}
+
+func TestGetResult(t *testing.T) {
+	doer := solvingDoer("token", 1)
+	instance := newTestInstance(t, doer)
+
+	taskID, err := instance.SubmitTask(NewHCaptchaTask("sitekey", "https://example.com/"))
+	if err != nil || taskID != "42" {
+		t.Fatalf("SubmitTask = %q, %v, want 42, nil", taskID, err)
+	}
+	if _, ready, err := instance.GetResult(taskID); ready || err != nil {
+		t.Errorf("first GetResult: ready, error = %v, %v, want false, nil", ready, err)
+	}
+	result, ready, err := instance.GetResult(taskID)
+	if !ready || err != nil || result.Token != "token" || result.TaskID != taskID {
+		t.Errorf("second GetResult = %+v, %v, %v, want the token of task 42", result, ready, err)
+	}
+	if polls := doer.received("res", "get"); len(polls) != 2 || polls[0].Params.Get("id") != taskID {
+		t.Errorf("polls = %+v, want two for task 42", polls)
+	}
+}
+
+// solveConcurrently solves an hCaptcha task solves times at once, spreading them over instances
+// in turn, and reports every error
+func solveConcurrently(t *testing.T, instances []Instance, solves int) {
+	t.Helper()
+
+	var group sync.WaitGroup
+	errs := make(chan error, solves)
+	for solve := 0; solve < solves; solve++ {
+		group.Add(1)
+		go func(instance Instance) {
+			defer group.Done()
+			result, err := instance.SolveTask(NewHCaptchaTask("sitekey", "https://example.com/"))
+			if err == nil && result.Token != "token" {
+				err = errors.New("unexpected token " + result.Token)
+			}
+			errs <- err
+		}(instances[solve%len(instances)])
+	}
+	group.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("SolveTask: %v", err)
+		}
+	}
+}
+
+func TestConcurrentSolves(t *testing.T) {
+	doer := solvingDoer("token", 1)
+	instance := newTestInstance(t, doer)
+
+	solveConcurrently(t, []Instance{instance}, 30)
+	if created := len(doer.received("in", "")); created != 30 {
+		t.Errorf("created %d tasks, want 30", created)
+	}
+}
+
+func TestMalformedAPIKeyRejected(t *testing.T) {
+	doer := answering(`{"status":1,"request":"1.5"}`)
+
+	for _, apiKey := range []string{"0123456789abcdef", testAPIKey + "0", "0123456789abcdef0123456789abcdeg"} {
+		if _, err := NewInstanceWithOptions(apiKey, WithHTTPClient(doer), WithPollInterval(time.Second)); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("NewInstanceWithOptions(%q) error = %v, want ErrInvalidAPIKey", apiKey, err)
+		}
+	}
+	if requests := len(doer.received("res", "getBalance")); requests != 0 {
+		t.Errorf("sent %d requests, want none", requests)
+	}
+	if _, err := NewInstanceWithOptions(testAPIKey, WithHTTPClient(doer), WithPollInterval(time.Second)); err != nil {
+		t.Errorf("NewInstanceWithOptions: %v", err)
+	}
+}
+
+func TestInstancesSharingClient(t *testing.T) {
+	doer := solvingDoer("token", 1)
+	instances := []Instance{newTestInstance(t, doer), newTestInstance(t, doer), newTestInstance(t, doer)}
+
+	solveConcurrently(t, instances, 30)
+	if created := len(doer.received("in", "")); created != 30 {
+		t.Errorf("created %d tasks, want 30", created)
+	}
+
+	instances[0].Close()
+	if _, err := instances[1].SolveTask(NewHCaptchaTask("sitekey", "https://example.com/")); err != nil {
+		t.Errorf("SolveTask after closing another instance: %v", err)
+	}
+}
+
+func TestBalanceStatus(t *testing.T) {
+	tests := []struct {
+		body string
+		err  error
+	}{
+		{`{"status":1,"request":"1.5"}`, nil},
+		{`{"status":0,"request":"ERROR_KEY_DOES_NOT_EXIST"}`, ErrKeyDoesNotExist},
+		{`{"request":"1.5"}`, ErrUnknownAPIError},
+		{`{"status":2,"request":"1.5"}`, ErrResponseStatus},
+	}
+
+	for _, test := range tests {
+		instance := newTestInstance(t, answering(test.body))
+		balance, err := instance.GetBalance()
+		if !errors.Is(err, test.err) || (test.err == nil && balance != 1.5) {
+			t.Errorf("GetBalance answered %s = %v, %v, want error %v", test.body, balance, err, test.err)
+		}
+	}
+}
+
+func TestMaxUserTurnPause(t *testing.T) {
+	var turns int32 = 1
+	doer := &fakeDoer{respond: func(request fakeRequest) (int, string, error) {
+		if request.Endpoint == "in" && atomic.AddInt32(&turns, -1) >= 0 {
+			return 200, `{"status":0,"request":"MAX_USER_TURN"}`, nil
+		}
+		return solvingDoer("token", 0).respond(request)
+	}}
+	var mutex sync.Mutex
+	var pauses []time.Duration
+	instance := newTestInstance(t, doer, WithSleep(func(ctx context.Context, duration time.Duration) error {
+		mutex.Lock()
+		pauses = append(pauses, duration)
+		mutex.Unlock()
+		return ctx.Err()
+	}))
+
+	if _, err := instance.SolveTask(NewHCaptchaTask("sitekey", "https://example.com/")); err != nil {
+		t.Fatalf("SolveTask: %v", err)
+	}
+	if len(pauses) == 0 || pauses[0] < userTurnPause*9/10 {
+		t.Errorf("waits = %v, want a pause of about %v first", pauses, userTurnPause)
+	}
+	if created := len(doer.received("in", "")); created != 2 {
+		t.Errorf("sent %d create requests, want 2", created)
+	}
+
+	atomic.StoreInt32(&turns, 100)
+	instance = newTestInstance(t, doer, WithMaxSlotRetries(2))
+	if _, err := instance.SubmitTask(NewHCaptchaTask("sitekey", "https://example.com/")); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("SubmitTask error = %v, want ErrTooManyRequests", err)
+	}
+	if created := len(doer.received("in", "")); created != 2+3 {
+		t.Errorf("sent %d create requests, want %d", created, 2+3)
+	}
+}
+
+func TestAPIKeyFromEnv(t *testing.T) {
+	envKey := strings.Repeat("e", 32)
+	previous, wasSet := os.LookupEnv(APIKeyEnv)
+	os.Setenv(APIKeyEnv, envKey)
+	defer func() {
+		if wasSet {
+			os.Setenv(APIKeyEnv, previous)
+		} else {
+			os.Unsetenv(APIKeyEnv)
+		}
+	}()
+
+	for apiKey, want := range map[string]string{"": envKey, testAPIKey: testAPIKey} {
+		instance, err := NewInstanceWithOptions(apiKey, WithSkipKeyValidation(), WithPollInterval(time.Second))
+		if err != nil || instance.APIKey != want {
+			t.Errorf("NewInstanceWithOptions(%q) key = %q, %v, want %q", apiKey, instance.APIKey, err, want)
+		}
+	}
+}
+
+func TestProxylessFallback(t *testing.T) {
+	var unsolvable int32 = 1
+	doer := &fakeDoer{respond: func(request fakeRequest) (int, string, error) {
+		if request.Action == "get" && atomic.AddInt32(&unsolvable, -1) >= 0 {
+			return 200, `{"status":0,"request":"ERROR_CAPTCHA_UNSOLVABLE"}`, nil
+		}
+		return solvingDoer("token", 0).respond(request)
+	}}
+	instance := newTestInstance(t, doer, WithProxy("1.2.3.4:8080", "HTTP"), WithProxylessFallback())
+
+	if result, err := instance.SolveTask(NewRecaptchaV2Task("sitekey", "https://example.com/")); err != nil || result.Token != "token" {
+		t.Fatalf("SolveTask = %+v, %v, want token", result, err)
+	}
+	requests := doer.received("in", "")
+	if len(requests) != 2 || requests[0].Params.Get("proxy") != "1.2.3.4:8080" {
+		t.Fatalf("create requests = %+v, want two, the first through the proxy", requests)
+	}
+	for _, param := range []string{"proxy", "proxytype"} {
+		if _, ok := requests[1].Params[param]; ok {
+			t.Errorf("fallback create params = %v, want no %s", requests[1].Params, param)
+		}
+	}
+	if instance.Settings.Proxy != "1.2.3.4:8080" {
+		t.Errorf("instance Proxy = %q after the fallback, want it unchanged", instance.Settings.Proxy)
+	}
+}
+
+func TestTimeoutKeepsTaskID(t *testing.T) {
+	instance := newTestInstance(t, solvingDoer("token", 1<<30), WithMaxSolveTime(50*time.Millisecond))
+
+	result, err := instance.SolveTask(NewHCaptchaTask("sitekey", "https://example.com/"))
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.TaskID != "42" || result.TaskID != "42" {
+		t.Errorf("SolveTask = %+v, %v, want a TimeoutError for task 42", result, err)
+	}
+
+	instance = newTestInstance(t, answering(`{"status":0,"request":"ERROR_NO_SLOT_AVAILABLE"}`), WithMaxSolveTime(50*time.Millisecond))
+	if _, err := instance.SolveTask(NewHCaptchaTask("sitekey", "https://example.com/")); errors.As(err, &timeoutErr) ||
+		!errors.Is(err, ErrSolveTimeout) {
+		t.Errorf("SolveTask before the task was created: error = %v, want ErrSolveTimeout without a task ID", err)
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	doer := answering(`{"status":1,"request":"OK_REPORT_RECORDED"}`)
+	instance := newTestInstance(t, doer)
+
+	if err := instance.CancelTask("42"); err != nil {
+		t.Errorf("CancelTask: %v", err)
+	}
+	if requests := doer.received("res", "reportbad"); len(requests) != 1 || requests[0].Params.Get("id") != "42" {
+		t.Errorf("reportbad requests = %+v, want one for task 42", requests)
+	}
+}
+
+func TestValidateTokenResolves(t *testing.T) {
+	var created, polls int32
+	doer := &fakeDoer{respond: func(request fakeRequest) (int, string, error) {
+		switch {
+		case request.Endpoint == "in":
+			return 200, fmt.Sprintf(`{"status":1,"request":"%d"}`, 41+atomic.AddInt32(&created, 1)), nil
+		case request.Action == "get":
+			return 200, fmt.Sprintf(`{"status":1,"request":"token%d"}`, atomic.AddInt32(&polls, 1)), nil
+		}
+		return 200, `{"status":1,"request":"OK_REPORT_RECORDED"}`, nil
+	}}
+	instance := newTestInstance(t, doer, WithValidateToken(func(token string) bool {
+		return token != "token1"
+	}, 1))
+
+	result, err := instance.SolveTask(NewHCaptchaTask("sitekey", "https://example.com/"))
+	if err != nil || result.Token != "token2" || result.TaskID != "43" {
+		t.Fatalf("SolveTask = %+v, %v, want token2 of task 43", result, err)
+	}
+	if reports := doer.received("res", "reportbad"); len(reports) != 1 || reports[0].Params.Get("id") != "42" {
+		t.Errorf("reportbad requests = %+v, want one for task 42", reports)
+	}
+
+	instance = newTestInstance(t, doer, WithValidateToken(func(string) bool { return false }, 1))
+	if _, err := instance.SolveTask(NewHCaptchaTask("sitekey", "https://example.com/")); !errors.Is(err, ErrTokenRejected) {
+		t.Errorf("SolveTask with every token rejected: error = %v, want ErrTokenRejected", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	doer := answering(`{"status":1,"request":"1.5"}`)
+	instance := newTestInstance(t, doer)
+	if err := instance.Ping(); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+	if created := len(doer.received("in", "")); created != 0 {
+		t.Errorf("created %d tasks, want none", created)
+	}
+
+	instance = newTestInstance(t, answering(`{"status":0,"request":"ERROR_KEY_DOES_NOT_EXIST"}`))
+	if err := instance.Ping(); !errors.Is(err, ErrKeyDoesNotExist) {
+		t.Errorf("Ping error = %v, want ErrKeyDoesNotExist", err)
+	}
+}
+
+func TestHTMLResponseRetried(t *testing.T) {
+	doer := answering("<!DOCTYPE html><title>Just a moment...</title>")
+	instance := newTestInstance(t, doer, WithRetryPolicy(RetryPolicy{MaxAttempts: 2}))
+
+	if err := instance.Ping(); !errors.Is(err, ErrHTMLResponse) || !errors.Is(err, ErrUnmarshal) {
+		t.Errorf("Ping error = %v, want ErrHTMLResponse", err)
+	}
+	if requests := len(doer.received("res", "getBalance")); requests != 2 {
+		t.Errorf("sent %d requests, want 2", requests)
+	}
+}